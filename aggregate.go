@@ -0,0 +1,167 @@
+package edb
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Fields events can be grouped by.
+const (
+	GroupActor      = "actor"
+	GroupRepository = "repo"
+	GroupType       = "type"
+)
+
+// Time bucket intervals. Weeks begin on Monday.
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+var (
+	// ErrInvalidGroup is returned when aggregating by an unknown field.
+	ErrInvalidGroup = errors.New("invalid group")
+
+	// ErrInvalidInterval is returned when aggregating by an unknown interval.
+	ErrInvalidInterval = errors.New("invalid interval")
+)
+
+// Aggregation represents a request to count events.
+type Aggregation struct {
+	// Events to count. Only the filters and time range are used.
+	Query Query
+
+	// Fields to group by: GroupActor, GroupRepository and GroupType.
+	GroupBy []string
+
+	// Groups events into time buckets of this length, if set.
+	Interval string
+
+	// Time zone of bucket boundaries. Defaults to UTC.
+	Location *time.Location
+}
+
+// Group represents the number of events sharing the grouped values.
+// Fields that were not grouped by are blank.
+type Group struct {
+	Time       *time.Time `json:"time,omitempty"`
+	Actor      string     `json:"actor,omitempty"`
+	Repository string     `json:"repository,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Count      int        `json:"count"`
+}
+
+// Aggregate returns event counts grouped by the aggregation's fields,
+// ordered by time, actor, repository and type. Counts are read from the
+// daily rollups when grouping and filtering by at most one field over whole
+// days in UTC. Otherwise every event in the time range is read.
+func (db *DB) Aggregate(a *Aggregation) ([]Group, error) {
+	// Validate grouping.
+	for _, field := range a.GroupBy {
+		if field != GroupActor && field != GroupRepository && field != GroupType {
+			return nil, ErrInvalidGroup
+		}
+	}
+	if a.Interval != "" && !contains([]string{IntervalHour, IntervalDay, IntervalWeek, IntervalMonth}, a.Interval) {
+		return nil, ErrInvalidInterval
+	}
+	loc := a.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	// Count every event in range, ignoring paging.
+	q := a.Query
+	q.Limit, q.After, q.Reverse = 0, "", false
+
+	var counts map[groupKey]int
+	err := db.db.View(func(tx *bolt.Tx) error {
+		// Use the daily rollups if possible.
+		var ok bool
+		if counts, ok = aggregateRollups(tx, a, loc); ok {
+			return nil
+		}
+
+		// Otherwise count each event.
+		counts = make(map[groupKey]int)
+		return query(tx, &q, func(e *Event) error {
+			var key groupKey
+			if a.Interval != "" {
+				key.time = truncateTime(e.Timestamp, a.Interval, loc).UnixNano()
+			}
+			for _, field := range a.GroupBy {
+				switch field {
+				case GroupActor:
+					key.actor = e.Actor
+				case GroupRepository:
+					key.repository = e.Repository
+				case GroupType:
+					key.typ = e.Type
+				}
+			}
+			counts[key]++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to a sorted list of groups.
+	groups := make([]Group, 0, len(counts))
+	for key, n := range counts {
+		g := Group{Actor: key.actor, Repository: key.repository, Type: key.typ, Count: n}
+		if a.Interval != "" {
+			t := time.Unix(0, key.time).In(loc)
+			g.Time = &t
+		}
+		groups = append(groups, g)
+	}
+	sort.Sort(Groups(groups))
+
+	return groups, nil
+}
+
+// groupKey represents the grouped values of an event.
+type groupKey struct {
+	time       int64
+	actor      string
+	repository string
+	typ        string
+}
+
+// truncateTime returns the start of the interval containing t in loc.
+func truncateTime(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch interval {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case IntervalDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case IntervalWeek:
+		return time.Date(t.Year(), t.Month(), t.Day()-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// Groups represents a list of groups sortable by time, actor, repository & type.
+type Groups []Group
+
+func (a Groups) Len() int      { return len(a) }
+func (a Groups) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a Groups) Less(i, j int) bool {
+	if a[i].Time != nil && a[j].Time != nil && !a[i].Time.Equal(*a[j].Time) {
+		return a[i].Time.Before(*a[j].Time)
+	} else if a[i].Actor != a[j].Actor {
+		return a[i].Actor < a[j].Actor
+	} else if a[i].Repository != a[j].Repository {
+		return a[i].Repository < a[j].Repository
+	}
+	return a[i].Type < a[j].Type
+}
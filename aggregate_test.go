@@ -0,0 +1,149 @@
+package edb_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure events can be counted by actor & repository over a time range.
+func TestDB_Aggregate(t *testing.T) {
+	db := MustOpenAggregateDB()
+	defer db.Close()
+
+	groups, err := db.Aggregate(&edb.Aggregation{
+		Query:   edb.Query{Since: MustParseTime("2000-01-01T00:00:00Z"), Until: MustParseTime("2000-02-01T00:00:00Z")},
+		GroupBy: []string{edb.GroupActor, edb.GroupRepository},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(groups, []edb.Group{
+		{Actor: "bob", Repository: "bob/foo", Count: 2},
+		{Actor: "bob", Repository: "susy/bar", Count: 1},
+		{Actor: "susy", Repository: "susy/bar", Count: 1},
+	}) {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+}
+
+// Ensure events can be counted by time bucket in a time zone.
+func TestDB_Aggregate_Interval(t *testing.T) {
+	db := MustOpenAggregateDB()
+	defer db.Close()
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+
+	for i, tt := range []struct {
+		interval string
+		loc      *time.Location
+		times    []string
+		counts   []int
+	}{
+		{interval: edb.IntervalDay, times: []string{"2000-01-01T00:00:00Z", "2000-01-03T00:00:00Z", "2000-01-04T00:00:00Z", "2000-02-10T00:00:00Z"}, counts: []int{1, 1, 2, 1}},
+		{interval: edb.IntervalDay, loc: loc, times: []string{"1999-12-31T00:00:00-05:00", "2000-01-02T00:00:00-05:00", "2000-01-04T00:00:00-05:00", "2000-02-09T00:00:00-05:00"}, counts: []int{1, 1, 2, 1}},
+		{interval: edb.IntervalWeek, times: []string{"1999-12-27T00:00:00Z", "2000-01-03T00:00:00Z", "2000-02-07T00:00:00Z"}, counts: []int{1, 3, 1}},
+		{interval: edb.IntervalMonth, times: []string{"2000-01-01T00:00:00Z", "2000-02-01T00:00:00Z"}, counts: []int{4, 1}},
+	} {
+		groups, err := db.Aggregate(&edb.Aggregation{Interval: tt.interval, Location: tt.loc})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(groups) != len(tt.times) {
+			t.Fatalf("%d. unexpected group count: %d", i, len(groups))
+		}
+		for j, g := range groups {
+			if !g.Time.Equal(MustParseTime(tt.times[j])) || g.Count != tt.counts[j] {
+				t.Errorf("%d. %d: unexpected group: %s=%d", i, j, g.Time, g.Count)
+			}
+		}
+	}
+}
+
+// Ensure unknown groups and intervals are rejected.
+func TestDB_Aggregate_ErrInvalid(t *testing.T) {
+	db := MustOpenAggregateDB()
+	defer db.Close()
+
+	if _, err := db.Aggregate(&edb.Aggregation{GroupBy: []string{"org"}}); err != edb.ErrInvalidGroup {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := db.Aggregate(&edb.Aggregation{Interval: "year"}); err != edb.ErrInvalidInterval {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure rollups stay correct as events are overwritten and replaced.
+func TestDB_Aggregate_Rollups(t *testing.T) {
+	db := MustOpenAggregateDB()
+	defer db.Close()
+
+	// Move an event to another day & type and replace a webhook event.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-04T04:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "webhook:d1", Type: "CreateEvent", Timestamp: MustParseTime("2000-01-05T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: json.RawMessage(`{"ref_type":"tag","ref":"v1"}`)},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "6", Type: "CreateEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: json.RawMessage(`{"ref_type":"tag","ref":"v1"}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fixed zone at UTC gives the same buckets but counts every event,
+	// so both paths must agree.
+	for i, a := range []*edb.Aggregation{
+		{GroupBy: []string{edb.GroupType}, Interval: edb.IntervalDay},
+		{GroupBy: []string{edb.GroupActor}, Interval: edb.IntervalWeek, Query: edb.Query{Since: MustParseTime("2000-01-04T00:00:00Z")}},
+		{GroupBy: []string{edb.GroupRepository}, Query: edb.Query{Repositories: []string{"susy/bar"}}},
+		{Interval: edb.IntervalMonth},
+	} {
+		rollups, err := db.Aggregate(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a.Location = time.FixedZone("UTC", 0)
+		scanned, err := db.Aggregate(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rollups) != len(scanned) {
+			t.Fatalf("%d. unexpected group count: %d != %d", i, len(rollups), len(scanned))
+		}
+		for j := range rollups {
+			if rollups[j].Count != scanned[j].Count || rollups[j].Actor != scanned[j].Actor || rollups[j].Repository != scanned[j].Repository || rollups[j].Type != scanned[j].Type {
+				t.Errorf("%d. %d: unexpected group: %+v != %+v", i, j, rollups[j], scanned[j])
+			}
+		}
+	}
+
+	// Rebuilding from events gives the same counts.
+	before, _ := db.Aggregate(&edb.Aggregation{GroupBy: []string{edb.GroupType}, Interval: edb.IntervalDay})
+	if err := db.RebuildRollups(); err != nil {
+		t.Fatal(err)
+	} else if after, _ := db.Aggregate(&edb.Aggregation{GroupBy: []string{edb.GroupType}, Interval: edb.IntervalDay}); !reflect.DeepEqual(before, after) {
+		t.Fatalf("unexpected groups after rebuild: %+v", after)
+	}
+}
+
+// MustOpenAggregateDB returns an open database with events for aggregation.
+func MustOpenAggregateDB() *edb.DB {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		panic(err.Error())
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T01:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "IssuesEvent", Timestamp: MustParseTime("2000-01-03T04:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-04T06:00:00Z"), Actor: "susy", Repository: "susy/bar"},
+		{ID: "4", Type: "PushEvent", Timestamp: MustParseTime("2000-01-04T07:00:00Z"), Actor: "bob", Repository: "susy/bar"},
+		{ID: "5", Type: "PushEvent", Timestamp: MustParseTime("2000-02-10T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+	}); err != nil {
+		panic(err.Error())
+	}
+	return db
+}
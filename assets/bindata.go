@@ -101,7 +101,7 @@ func index_html() (*asset, error) {
 	return a, nil
 }
 
-var _index_js = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb4\x58\x4b\x8f\xdb\x36\x10\xbe\xfb\x57\xb0\x3a\x04\x12\x6c\xd3\xde\x4d\x1f\xc0\x3a\x5b\x20\x0d\x02\x34\xa7\x02\x2d\x7a\x5a\xf8\xc0\x88\xb4\xc5\x46\x2f\x90\xd4\x46\x6e\xe2\xff\xde\x19\x92\x92\x28\x59\x76\x16\x2d\xea\x43\x22\x72\x66\x38\x33\xdf\x3c\x38\xdc\x67\xa6\xc8\x67\xc9\x4d\x46\xc8\x23\xf9\x61\xbb\x5d\x2d\x08\xfc\x32\x21\x8f\x99\x71\x3b\xbb\xc5\x02\x99\x6a\xc6\xb9\x2c\x8f\xb0\x77\x0f\x5b\xb8\xd3\xbe\x6d\xa5\xfe\xb5\xe3\xec\x76\x3f\xaa\x46\x67\x17\xbb\x69\x55\x1a\xd1\x9a\x7e\x3f\x94\x5d\x86\x32\x5e\x5b\xce\x4e\x55\x83\x8c\xfc\x35\x75\xdf\xb4\x66\xe9\xa7\x38\xb1\xe6\x51\x2d\xff\x16\xf1\x93\xb3\x7b\x4d\xbe\x5f\x75\x06\xaf\x27\x8a\xd6\xbd\xd9\xc0\xb5\xf7\xc2\xcf\x2c\x6f\x44\x7c\x68\xca\xd4\xc8\xaa\x8c\x79\x42\xbe\x10\x25\x4c\xa3\x4a\xc2\x1d\x71\x47\xce\x89\x37\x44\x3f\x1f\x9d\x15\x5a\xe4\x22\x35\x71\xf4\xb1\xe2\xa7\x28\xa1\xac\xae\x45\xc9\xe3\x08\xe8\x91\x3f\x97\x19\xa3\xe2\xc8\x1a\x15\xad\x1c\xa8\x23\x8a\xb3\x31\xea\x8c\x4d\xec\xf9\x78\x3a\x9c\xd1\x9f\x37\x39\xcd\x28\x56\xea\x43\xa5\x0a\x10\x73\x8b\x9c\x19\x11\xdf\xaf\xee\x93\xa8\x33\xb1\x75\x06\x1a\x59\x08\xaa\x53\x96\x8b\x38\xa1\xc0\x79\x04\x84\xb6\xde\x8e\x7d\x12\x84\xcc\xfb\x83\x4a\x61\x05\xcc\x4e\xa8\x4d\x68\xa5\xa4\x28\xad\x8f\xc6\x54\x45\x94\x04\x11\x1d\x84\xec\xb2\x0b\x44\x0b\x62\xee\x0b\x90\x8c\x2c\x09\xdc\x00\x63\x7b\x78\x01\x5d\xcb\x80\x3f\x25\x0e\x4a\xa0\xf0\xce\x6e\xf5\x20\xfb\xa0\x81\x8e\xab\x40\xa4\x39\xd3\x1a\x41\xf0\xbc\x2f\x82\x69\xbb\x8a\x20\xbb\x62\x87\xf7\x7a\x94\x1a\x09\x10\x22\x87\xa1\xdf\x9f\x68\x9e\xea\x6d\x09\xa2\x05\xa4\x17\xf3\x5b\x34\x50\x00\x5d\x14\xcf\x80\x2c\x42\xff\xb4\xdf\x2d\x00\xc9\xbf\x34\x02\xb6\x71\xdb\x76\x15\x82\x26\x94\xaa\xd4\x8a\x70\x66\x58\x87\x1f\x7e\x53\x70\xf1\x3d\x4b\xb3\x71\xf2\xf6\xf0\x72\x9b\x03\xda\xb0\xa2\x0e\x52\x02\x61\x61\x86\x4a\x5d\x41\x09\x29\x2d\xe2\x80\x2d\x08\x04\xfe\xdf\x1b\x89\xca\x00\x99\x49\xcc\x6c\xbc\x16\x9d\xee\x81\xe2\x4d\xd8\x6c\xc8\xfb\x16\xc0\x4f\x0d\x29\x64\xb9\x29\x58\x4b\x7a\x45\x9a\xb0\x92\x13\x2d\x0c\xe1\x55\xc1\x64\x49\xad\x44\x4b\xdd\x2a\x06\x5b\x01\x51\xcc\x3d\x0f\x48\xc1\xea\x2b\x4e\xf6\x95\xda\x9f\xdd\xf9\x90\x24\xde\x64\x30\xe4\x5d\x55\x6a\xa3\x9a\xd4\x54\x8a\x30\xfc\x77\xa3\x44\x5d\x91\x4c\x0a\xc5\x54\x9a\x9d\x9c\x7e\x0c\x0c\xee\xeb\x5f\x4e\x6f\x2d\xeb\x23\xf9\x22\xf9\x03\x81\xb2\x49\x33\x99\x73\x25\xca\x87\xa7\xfd\x8a\xe4\x55\xf5\xa9\xa9\x1f\xc8\x97\xf3\x79\xd7\x0b\x4a\x0e\xec\x77\x6e\x0d\x18\xc7\x76\xef\x71\xbb\x23\xf2\x8d\x77\x22\x17\xe5\xd1\x64\xb0\xb1\x5c\x86\x1e\xd8\x74\x00\x59\xc7\xf5\x24\xf7\xde\x6c\x6f\xfa\x87\x83\xaf\x38\x01\xf9\x66\x34\x64\x85\xcc\x8d\x80\x32\x3c\x11\x87\x11\xed\xb9\xe5\x21\xfe\xce\xf2\x52\x51\xd4\xe6\x04\x91\x78\xf5\x8a\xc4\x62\x80\xc6\xb5\xb3\xdf\x0e\x40\x79\x43\x3c\xa7\xc3\x39\x79\xda\xee\x03\xea\xd7\xaf\x64\x5e\xec\xe7\xa9\xd8\x5d\x20\x96\x84\x6e\xe1\x0f\x4b\x43\x96\xd0\x40\xfb\xdd\xf3\xc8\xb7\x77\x4a\x40\x5d\xba\x88\x78\x54\x47\xde\x84\xc1\xa0\x8e\xfe\x24\xa8\x65\xdf\x93\xc7\x47\x52\x36\x79\x3e\xd5\x89\x70\xb2\x30\x7a\x92\x2f\x97\x2b\x62\x4e\xb5\x78\x20\x91\xa5\x40\x69\x95\xac\x80\xa5\x3f\x2b\x08\x2f\x99\x8d\xef\x90\x6c\x81\x3d\x9d\x0c\xad\xb1\x01\xda\x83\x92\x1b\xec\x17\xe6\x3b\x2b\xbf\x01\x8d\x4d\xd3\xa6\xe4\xc2\x3b\x45\x17\x73\x7e\xde\x52\xb4\x0b\x01\x65\x63\x0e\x2b\x27\x61\xeb\x74\x13\x4e\x6b\xc4\x1c\x9a\x48\x08\xc0\x1c\x8e\x5b\x11\x9b\x14\x50\x3a\xe7\xd1\x69\x6c\x06\x39\x94\x9a\x00\x77\xcb\x4c\x6b\xcd\x15\xd0\x3e\x94\xa9\x12\x05\x24\xa6\x53\x4f\xaa\x83\xe5\xa6\x1d\xcb\xe2\xdb\x0a\x5c\x36\x2f\x97\xbe\x8b\xb8\xe3\x8f\x14\x3a\x60\x53\x8c\xf2\x31\xf1\x23\xc0\xdb\x3c\x8f\x23\x5a\x56\x5c\xc0\x18\x80\x8d\x32\xf6\x03\x0a\x6e\xe9\xa0\x89\x8f\x07\x0b\x09\x1d\xab\xfa\xc3\x28\x18\x46\xa0\x97\x42\xbb\xf2\xb6\x51\xb8\x7c\xf3\xa1\xd7\x39\x1d\xf0\x75\xd1\x31\x4a\x6c\x02\x30\x2c\x74\x0c\xd4\xee\xc4\xc9\x6a\x84\x24\x34\x0d\x43\xc6\x6c\xb0\x13\x27\x41\x8f\x19\x48\xdd\x4c\x93\x4a\x95\xe6\x22\x4a\x82\x93\xa8\xbd\x45\xa5\xbb\xc2\x29\x6f\x14\xb3\x9f\x30\x0f\x26\x23\x7d\xfe\xda\x53\xd1\x55\xc7\x95\x9f\xa6\x2e\x73\xdd\xa9\xd5\xd8\x3d\x5d\x80\xdc\x2d\x61\x41\x1f\xf2\xde\xc2\x8a\xed\x12\xdd\xbd\x18\x0f\x46\x56\x84\x63\xc0\xac\x35\xc1\x6c\x80\x93\x01\xa7\x2d\x8e\x01\x2b\xf7\x7d\x72\x23\xc1\x39\x19\xab\xee\x55\x7e\x1b\x25\x01\x99\xf0\x1f\x20\x9a\x57\x6b\xa4\x19\x69\x75\x54\x77\x29\x5c\x9b\x62\xb1\x5c\xb1\xc0\x5d\xc1\xe2\xe9\xc3\x71\xc3\xf0\x34\x32\x0f\x0e\xcc\xd7\x15\x0c\xd9\xd2\x9c\xc0\xd2\xed\xbf\x75\x73\x72\xce\xdd\x24\xf4\xbf\x8b\xa2\x7a\x16\x36\x4d\x05\xf7\xbe\x2c\xc2\xe4\x85\xda\x44\x8e\x78\x22\xf7\x67\xcd\x31\x65\x4c\x26\x88\x2d\x5d\x1c\x40\xa0\xda\x05\x4c\x43\xf6\x14\x3a\x93\xde\xe3\x89\x6c\x0c\xd5\xc8\x74\xfd\x59\x1a\x98\xaa\x1c\x6e\x17\x77\x1a\xd3\xc2\x03\xf9\xd0\xe7\x91\xed\x90\xb6\x07\xec\x66\x98\xdd\xa5\x33\x70\xbb\xb6\x3d\xc3\xce\xc5\x81\x35\xb9\x19\x38\x67\x78\x46\x0d\x35\x48\xce\xc1\xcf\x17\x95\xea\xff\x54\x22\x97\xcd\xc4\xe7\x2b\xc5\x44\xbb\x32\xc3\xe1\x0f\x2e\xa7\x21\x4f\xe7\x2e\x22\xfc\x75\x36\xdd\x82\x64\xc8\x7a\xbc\x96\xd0\x40\x78\xec\x39\x73\xdd\x63\x6e\xb8\x3b\x6e\x77\xc1\x8b\xca\xe8\x41\xe3\x98\xc9\x11\x7d\x2d\x8a\x29\x5d\x9b\x13\x3c\x9d\xac\xe8\x9a\x95\x69\x66\x67\x8d\xa8\x90\x9c\x4f\x3a\x05\x16\xd1\x6d\x40\x2e\x5c\xa1\x3a\x97\x29\x8e\xeb\x76\x21\x61\x26\x68\x61\xe8\x8a\x36\x51\xb2\xbc\x83\xcb\xa8\xf9\xa8\xdd\x6d\x02\xd3\x2a\xb4\x10\xb2\x21\xaf\x27\xb7\xea\xb9\x1f\xef\x87\xb9\xd8\x0f\x60\xd0\x66\x1b\x57\x51\xf6\x35\xd3\x0d\x95\xc0\x31\x7d\xdd\xd4\xcc\x64\xde\x13\xa0\x5e\xbe\x73\x18\x9c\x38\x43\xc7\x37\x20\x92\xee\x43\x92\xbb\x54\xed\xab\xc6\x47\xa2\xd3\xd6\xc5\x80\xb6\xd4\x3f\xb3\x46\x01\xb8\xf9\xbe\x0b\xff\xe2\xe0\x1e\x75\xa3\xab\xd5\xbe\x79\x6f\xe8\xeb\x9e\x69\x7d\x9c\xac\x94\xdd\x0d\x36\x83\xbb\x5f\xc1\xc7\x5c\x07\xc5\x24\x59\xff\x38\x43\xe8\x1f\xfe\x81\xa5\xcb\x9f\xf0\x41\xb5\xf8\x27\x00\x00\xff\xff\x1b\xaf\x5e\x6b\x7f\x11\x00\x00")
+var _index_js = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xb4\x59\x6d\x6f\xe3\xb8\xf1\x7f\xef\x4f\x31\xd0\x1f\xd8\xbf\x04\x2b\xb4\xb3\x7b\xd7\x02\x71\xdd\x43\x6e\xb1\x45\xd3\x17\xdd\xc3\x2e\xee\x95\xe1\x16\x8c\x38\xb6\xd8\x95\x48\x97\xa4\x1c\xfb\xf6\xfc\xdd\x8b\x21\xa9\x27\x47\xd9\x1c\x7a\x28\x6c\x24\x12\x39\x9c\xe7\xf9\x0d\x49\x1f\xb9\x81\x27\x29\x5c\x09\xb0\x86\xef\x97\xcb\x7c\x06\x00\x50\xa2\xdc\x97\x2e\x8c\xac\x66\x33\x22\x3a\x70\x21\xa4\xda\xc3\x1a\xde\x2e\x57\x7e\xe4\x74\x7f\x92\xf6\xaf\x2d\x65\x3b\xfa\x68\x1a\x5b\x3e\x1b\x2d\xb4\x72\x78\x72\xdd\xf8\x70\xed\x7c\xb8\x26\x4a\xab\xf8\x59\x37\xc4\x56\xbc\x63\xe1\x99\x1d\x78\xf1\x25\xcd\xbc\x7a\xcc\xca\x5f\x30\xdd\x04\xbd\x6f\xe0\xbb\xbc\x55\xf8\xe6\x4a\xd0\x4d\xa7\xf6\x0d\x7c\xb7\x8d\x8b\x8f\xbc\x6a\x30\xdd\x35\xaa\x70\x52\xab\x54\x64\xf0\x15\x0c\xba\xc6\x28\x10\x61\x72\x05\x97\x2c\x2a\x62\x8f\x64\xb2\x78\xc7\x2c\x56\x58\xb8\x34\x79\xd4\xe2\x9c\x64\x8c\x1f\x0e\xa8\x44\x9a\xd8\xe3\x3e\x89\x7c\xb9\x73\x26\x4d\xbc\x52\x49\x1e\x9c\x3a\x9a\x09\x3a\x26\xad\xb2\x99\xe7\x4f\xdc\xed\x71\xdf\xf1\xbb\xe2\xe6\x0c\x57\x76\xa7\x4d\x9d\xe4\x10\x5e\x2a\xee\x30\x7d\x9b\xbf\xcd\x92\x56\xc5\x53\x50\xd0\xc9\x1a\x99\x2d\x78\x85\x69\xc6\x0c\x57\x7b\x4c\x37\xcb\xa8\xc7\x36\x1b\x84\x2c\xda\x43\x42\x4f\xd2\xa6\x59\x5c\x74\xca\x98\x36\x12\x95\xb7\xd1\x39\x5d\x27\x71\x91\x8f\x4e\xbf\xc8\xbf\xb6\x81\x38\xa5\xa7\xf8\xa4\x55\x9a\xf8\x29\x54\x22\xc9\xa1\x73\x6f\x06\x5f\x3d\x01\x7d\x17\x0b\xf8\x5c\xea\x27\xe0\x55\x05\x85\x6e\x94\xb3\xf0\x54\xa2\x02\x57\x62\x94\x22\x2d\x14\x15\x72\x83\x82\x75\xab\xe4\x2e\xf5\x93\x0c\xeb\x83\x3b\xa7\xd9\x90\x23\x7d\x0c\xee\x0c\xda\x32\x0d\x1c\xb3\xd5\xd5\x24\x05\xb6\x1f\xbb\xcc\xba\xc7\xc5\x02\x3e\xba\x12\xcd\x93\xb4\x08\x3b\x74\x45\x09\x5a\x55\x67\xaf\x4d\xab\x9d\x74\xa5\x1c\xea\x87\x27\x87\xca\xf5\xba\x91\x7f\xc2\x18\xac\x21\x6a\xe9\x5f\xd3\x81\x1e\x44\xd4\x98\x0a\xd6\x90\x2c\xac\xe3\xce\xfe\xb0\x37\xba\x39\xac\x79\xe1\xb4\xc9\x0d\x1e\x74\x02\xf3\x8e\x9a\xbe\xc9\x1b\x2b\x55\x81\xeb\x04\xe6\x80\xaa\xd0\x02\x7f\xfe\xf4\xf0\x5e\xd7\x07\xad\x88\x77\x90\xb8\x59\x6e\x99\xd3\x0f\x9f\x3f\x7e\x76\x46\xaa\x3d\x39\xe6\x9a\x4b\xa3\x9c\xac\x5e\xe1\x72\x7b\xcd\xa5\x57\xdc\x3b\xe5\x6f\x9f\x3f\xfe\x3d\x6d\x4c\x35\x88\x29\x1a\xa3\x4d\x0e\x82\x3b\xfe\x52\x30\x68\x0e\x7e\xfd\x15\x36\xdb\x01\xbf\x4b\x7c\xee\xaa\x2b\x56\x2b\xac\xe1\xc5\x0a\x28\x2a\x6e\x2d\x65\x7f\xa4\xfd\x4d\xf5\xb1\xcc\xc9\xe6\x34\x14\xda\x4d\x5c\x19\x00\x26\x83\x39\x24\xa1\x78\xe2\xf8\x95\xe4\x6b\xb9\x27\xe0\x27\x69\x93\xec\xb7\xd3\xfb\x3c\xa0\x05\x8b\x05\xdc\xff\xf4\x00\x4e\x7f\x41\x95\x83\xdc\xf9\x44\xb2\x68\x8e\x68\xc0\xe0\xbf\x1b\x69\xd0\x82\x56\xc8\xe0\xc1\x41\xc1\x15\x3c\x22\x1c\xb8\xb5\x28\x40\xab\x02\x21\xa4\xde\x6c\xb1\x00\x2e\x84\x41\x6b\x81\x5b\x48\xfe\xcf\xf3\x5b\x33\xc6\x12\xe0\x4a\x80\xb4\x60\xb0\xc6\xfa\x11\x0d\x0a\xd8\x69\x03\xe4\x03\x03\x47\x69\xa5\xb3\xcc\x3b\xda\x2f\x81\x35\xf4\xb0\xd7\x06\x8e\x66\x6b\x58\xc3\xc2\x93\xac\xd3\xcd\x3f\xde\x6c\xe7\xd9\x82\xe1\x09\x8b\xf4\x49\x2a\xa1\x9f\x58\xa5\x0b\x4e\x81\x67\x25\xb7\x65\x8c\xa1\xdc\xa5\xf5\x30\xf8\x44\x53\x7d\x76\xda\xf0\x3d\x32\x8b\xee\xc1\x61\x9d\x26\x9e\x69\x92\x43\xbd\xb9\x1d\x26\xc2\x14\x5f\x2a\x8f\x24\xe6\xc7\xac\x2f\xdd\x31\xe3\xfd\x98\x71\xb6\x9a\x5d\x32\x2a\x35\xf2\xd1\x87\x23\x2a\xd7\xe2\xca\xe3\x19\x7c\x79\xc1\x1b\xa0\x02\xcb\xbd\xa7\xc8\xfd\x04\x93\xe0\xe1\x11\xf4\xce\x23\x11\xd2\xba\xe8\xa7\xb8\x7a\x0d\x9b\x6d\x00\x3f\x22\xff\xe4\xa9\xc3\xd8\xac\xaf\x89\xb6\x98\xa5\x72\x68\x8e\xbc\x5a\x0b\x7e\x4e\xa6\xaa\xe4\x6c\x5b\x47\x09\x7e\xb6\x84\xa4\xf4\xcf\x17\xc7\x2a\xc0\x11\x01\x23\x3a\x10\xba\xe6\x52\xc1\xce\xe8\x9a\x02\x0f\x3b\x69\xac\x83\x37\x50\x71\xeb\x68\x91\xc7\xa3\x4e\xdf\x18\x05\xe2\xc9\x2a\x54\x7b\x57\xc2\x9f\x61\x39\x8c\x09\x19\x10\x78\xf4\x1d\x82\x9a\x09\x77\x4c\x5a\xcd\x0e\xdc\x58\xf4\xeb\x3d\x94\xc8\x1a\xb3\xbc\x5b\x4b\x1f\x2f\xf7\xd5\xb5\x03\x05\x6e\x6e\x23\x9f\x3e\xd4\x23\x07\x7a\x65\xf2\x8e\x9f\xe0\x67\xa6\x77\x3b\x8b\x2e\x25\x51\x39\xdc\x66\xdb\x7e\xe5\x89\x05\x7f\xa4\x1d\x8b\xc8\x36\x62\xf8\xf3\x48\x3c\x83\xd5\xd7\x31\xab\x0b\x78\x0f\x58\xab\xd9\x35\x98\x75\x9d\xa5\x9b\x59\x2c\xe0\x3d\x0d\x82\xc2\xa7\x18\x10\xaa\x4d\x57\xe2\x19\xb8\x31\xf2\x88\x7d\x8f\xb0\xce\x20\xaf\xdb\x86\x40\xd8\x77\x89\x19\xfb\xf1\x80\xca\x02\x8f\x14\x94\x8f\x03\x76\x5d\x16\x04\xc0\x60\xf0\x21\x8a\x31\xb1\x3d\x79\x94\x68\x01\xa2\x28\xb9\x71\xd0\xa8\xca\xa3\xc4\x30\xcb\x4b\x6e\xe1\x11\x51\x41\xd8\xc1\xa0\x08\x59\xd4\xf5\x34\x36\x6b\x7d\xd4\x69\x1a\xfd\x33\xec\x5b\x41\xa9\x45\xa0\x88\x65\x2a\x77\xa9\x2f\xc3\xa1\x3f\x89\x7e\xbe\x86\xe4\x07\x5e\x14\x68\xed\x3f\x3d\xc1\x4b\x1d\xc8\x4f\x8e\x83\x4a\x32\xad\x6e\x4c\x41\xf9\x42\xde\xf0\x56\x7f\xf6\x23\xd4\x85\x22\x75\x20\x61\x5a\xd5\x68\x2d\xf7\xd5\xd9\x45\xba\xb6\xfb\xa1\x46\xc4\x91\xe6\x7d\xc6\x7a\x6e\x36\xdd\x50\x53\x8b\x39\x5c\xdb\x3d\xa3\xe0\x67\xdb\x6c\xb3\xdc\x8e\x63\xfc\xa0\x0a\x82\x56\xe5\xfa\x4d\x81\x07\x58\x7a\xf3\x1e\xf9\x7f\x3b\x82\x99\xf1\xc6\xc0\x47\x09\xd6\x31\xc7\xd8\x4e\x56\x0e\xcd\x78\xe3\x39\xb1\x57\x01\xc1\x02\xcb\xf5\x1a\x30\x3e\xbe\x79\x03\x82\x51\x46\x5b\xe9\xb4\x39\x87\xa9\xfe\xbd\xcf\xd8\x4b\xb0\xa1\x7d\x95\xbb\x34\x2a\xb1\x06\xd5\x54\xd5\xb5\xc4\x56\xc3\xaf\x5e\xcc\x5d\x2b\x2f\x87\x9e\xf7\xdd\x48\x52\x1e\x8c\xb9\x83\xe5\x65\xf5\x9c\x93\x65\x87\xa6\x2d\x97\x18\xa8\x1e\xce\x3b\x2a\xe6\xff\xce\xe7\x63\x57\x7f\xa0\x3d\x8d\xf0\x7e\x8e\x30\xe8\x34\x48\x55\x54\x8d\xc0\xde\xdf\x6c\x12\x57\xc4\xbb\x76\xd7\xd5\x8d\xb2\x42\xab\x82\xbb\x74\x83\x1e\x90\xac\xe3\xf5\x61\x9b\x65\xaf\xa0\xcb\xef\xdf\x73\x06\x5b\x2f\xab\xd9\xc5\xb7\xff\xbf\x10\x46\xa1\x05\xca\xb7\x1e\xd7\xef\x7f\x7a\xc8\xc1\xa2\xf2\x27\x13\x1a\xf0\x85\x10\x37\x08\x06\xa9\xa3\xd3\xae\xa0\x2f\xcc\x1e\xea\xfc\x46\xac\xe0\x55\xf5\xc8\x8b\x2f\xad\x56\x94\x6c\xa7\xd2\x10\x8a\xbd\x63\xff\xb2\x5a\x0d\x2a\x65\xaa\x48\x4f\xa5\x61\x25\x72\x81\x26\x4d\xee\x1b\x57\x6a\x23\x7f\xf1\x8d\x98\xf6\x2f\x3f\xd2\xf6\xdb\x00\x95\xec\xb8\x3e\xdb\x95\x7b\x74\x69\xa7\x41\x6b\xe8\x7b\xad\x8e\x68\x9c\x85\xce\xdb\x64\x03\x70\xa8\xa4\x75\x84\x6c\x11\xd5\xc2\x41\x0b\x45\xef\x8c\x08\x6f\xbd\xb1\xc3\x42\x1d\xc2\x35\x3d\x4f\x00\x35\x0d\x53\x67\xfa\xc0\x8b\xf2\x85\xe2\x12\x7d\x0e\x7c\xb3\x9b\xf5\x64\xad\xd1\xd9\x6a\xb8\x15\x21\x51\xad\xc1\x9f\x50\x18\xfe\xe4\x21\x3f\x82\xaf\x0f\xef\x95\xc1\x2f\x6c\x47\x06\xa1\xbd\xca\xa4\xa8\xb5\xef\x2f\xca\x3a\xd3\xf8\x55\xbe\x3c\x17\x54\x86\x50\x4a\x34\xdc\x14\xe5\x99\x75\xa1\xa7\x71\xfb\xe3\xf9\x9e\x88\x60\x0d\x5f\xa5\xb8\x83\x65\x0e\x45\x29\x2b\x61\x50\xdd\x6d\xb6\x39\x54\x5a\x7f\x69\x0e\x77\xf0\xf5\x12\x0b\x97\x16\x4a\x01\x6b\xb8\x0d\xef\x3b\x6d\x52\x3f\xb6\x5e\xae\x40\xfe\x29\xd6\x73\xd8\x57\xac\x40\xce\xe7\x53\x98\x1a\xa8\x36\xf2\x0a\x36\xdf\x1b\xe4\x0e\xa3\xc5\x41\x72\x5f\xb8\x72\x97\x0e\x15\x66\x61\x7e\x13\xa1\x67\xfb\x12\x54\x91\xc8\x88\x8b\xc1\x42\x29\xe6\xf3\x1c\xdc\xf9\x80\x77\x90\xf8\x99\x24\x07\xc5\x6b\x1c\xc0\x58\xe7\x02\x98\xf4\x41\xfb\x19\xe9\xd3\xae\x09\x58\xe6\x19\x65\xdf\x20\x7f\xa6\x7e\x30\xbb\x5f\x71\x99\x72\x0d\x49\x84\x46\x09\x8c\x46\xb1\xd9\x94\x9d\xdf\x12\xd4\x0b\x90\xbb\x94\x8f\x29\x7a\xbc\xfe\xa6\x3b\x89\x6c\xd2\x9b\x71\xef\xd4\x3a\x73\x08\xff\xfe\x7e\x84\xe0\x7f\xc4\x8d\x4f\x78\x8e\x56\x5d\x39\xee\x5b\x6a\x7a\xaf\xbe\xe0\xb4\x7b\x21\x62\xab\x72\x3a\x68\x40\x05\x36\x6e\xb8\x2f\xf3\x0e\x77\x3a\x30\xa7\x9e\xe9\xb9\xc4\xda\x0e\x22\x7c\xf3\x6f\xea\x51\x4e\x66\xf1\xb6\xe7\xbe\xaa\xd2\x84\x29\x2d\x30\xc9\x88\x8c\xa7\xf1\x2e\x8a\x86\x6c\x0e\x2f\xdc\x21\x49\xc1\x9c\x6e\x8f\xd3\x74\x97\x14\x95\x64\x84\x9a\x3d\x44\x05\x19\x52\x8f\xa0\x99\xc2\x8f\x74\xa0\xa0\x7b\xa1\x96\x80\xf9\x91\xf4\x6a\x77\x8e\x27\xe9\x60\x4c\x76\x92\x2e\x1d\xb6\xb1\x7e\xaa\xbd\xbe\x2a\xa4\x29\x2a\x8c\x87\xe8\xa8\x96\x3f\x44\x4b\x12\x94\x66\x4c\x34\xc6\xb7\x82\xf4\xfb\xe5\x72\x48\xd5\x1d\x74\x4d\xf2\xa2\xe1\x26\x5e\x9c\x3d\xcf\xf7\x20\xd6\xfa\xcd\x93\x8f\x94\xf5\x07\x32\xef\xf4\x3e\x86\xde\xad\xb0\x06\x6f\xee\xd5\x31\xfb\x4a\x8b\xe1\xc1\x7f\x52\x9b\xc1\x6d\x00\xb5\x32\xc1\x4e\x74\xf0\xf7\xf7\x02\x82\x9d\xe9\x39\x4b\x2e\xd9\x58\x74\x27\xf2\x75\x2f\x61\xc5\xcf\xbf\xc3\x45\xd3\x62\x9d\x74\x23\xa9\x61\x76\x6a\xdf\xd8\x33\xa4\x92\xa5\x22\x4f\xc8\x97\x09\x05\xa0\x67\xd7\x5f\x97\x8c\xd4\xdb\xc9\xaa\xba\xd1\x07\x5e\x48\x47\x07\xd6\xe5\x7f\x6b\xe6\x15\x9f\xdb\xab\xd0\x7f\xc2\x5a\x1f\xd1\xa7\x29\x8a\x68\x69\x37\x4f\xa3\xcc\x78\x8a\x51\xc6\x2e\x16\xf0\xf3\x41\x50\xca\x50\x63\xf5\xb8\x43\x7b\x02\xda\x40\xf0\xa2\xf4\x5c\xd8\x44\x7a\x8f\xef\x60\xc6\xae\xea\xc8\xe9\x6b\x9f\xa4\x2b\xca\x34\xf8\xed\x1a\x14\x0b\x6e\x31\x3a\xf2\xae\xf5\xb0\x7f\xf5\x72\x93\xd5\x04\xb1\x4f\xe6\x01\xb5\x7f\x9f\x22\x17\xb8\xe3\x4d\xe5\x7a\xca\x09\x9a\x11\xa8\x5e\xb2\x09\x3b\x7f\x53\xa9\xfe\x8f\x4a\xe4\x39\x98\xc4\x7c\x65\x74\xb5\x3f\xce\xcf\x81\x36\xbe\x41\xf5\x79\x3a\xd5\x8c\x06\x5b\xac\xf6\x0a\x68\xd2\x25\x2d\x91\x60\xd4\x9a\x48\x41\xb8\xf1\x1b\xd5\xf6\xde\xbe\x23\x1e\xc1\xd0\x84\xe2\xd7\x95\xd1\x39\x4d\x50\x45\x24\xec\x1d\xd6\xd7\xf3\xd6\x9d\x2b\x0c\x4b\x6f\xb8\x2a\x4a\x4d\x40\x98\xd4\x52\x88\x2b\xa4\x00\x78\xcd\x21\xcf\x4c\x61\xb6\x92\x05\x6d\x40\xc9\x2e\x26\x95\xc0\xd3\xc7\x5d\x9a\x2c\x92\x6c\x7e\x9b\x31\xdb\x3c\xda\xd0\x4d\x96\x39\x9d\x03\x61\x01\xef\xae\x3a\x6b\x8b\x29\x97\x6c\x36\x1b\x23\x2f\xc1\x6c\x13\x2a\xca\xdf\x5f\xb6\x67\xa8\xc5\xa2\xfd\xad\xa4\xc3\x8b\x03\x77\x65\xb4\x64\xb1\xe8\x5d\xd2\x56\x55\xc2\x0d\xf2\x89\x79\xba\xee\xa7\xa9\xb7\xc3\xa9\xd0\x54\xa9\x67\xb6\x91\x68\xa5\xb5\x31\x60\x27\x16\x2f\x56\x5f\xcc\xda\x41\x82\x86\x1b\xdd\xe1\x8f\x4b\x1e\xc1\xbb\xc5\xbe\xb5\xfa\x9f\x37\xbe\x21\xaf\xbd\x98\x6d\xbd\x16\x1a\xb2\x1f\x1d\x0c\x0e\x7a\xbf\xc1\x62\x12\x41\x29\x49\x6e\xfe\x30\x31\xd1\xfd\xc6\x33\xd0\x74\xfe\xc7\x6c\x35\xbb\xcc\xfe\x33\x00\x96\x1b\x2c\x78\x6a\x1b\x00\x00")
 
 func index_js_bytes() ([]byte, error) {
 	return bindata_read(
@@ -116,7 +116,7 @@ func index_js() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindata_file_info{name: "index.js", size: 4479, mode: os.FileMode(420), modTime: time.Unix(1430838665, 0)}
+	info := bindata_file_info{name: "index.js", size: 7018, mode: os.FileMode(420), modTime: time.Unix(1430838665, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -21,7 +21,20 @@ var xAxis = d3.svg.axis().scale(x).orient("bottom");
 var brush = d3.svg.brush()
     .x(x)
     .on("brushend", function() {
-        refresh();
+        // Show all counts when the brush is cleared.
+        if(brush.empty()) {
+            refresh(counts);
+            return;
+        }
+
+        // Otherwise fetch only the counts within the brush extent.
+        var extent = brush.extent();
+        var url = "/stats?group=actor,repo" +
+            "&since=" + encodeURIComponent(extent[0].toISOString()) +
+            "&until=" + encodeURIComponent(extent[1].toISOString());
+        fetchJSON(url, function(error, data) {
+            refresh(data || []);
+        });
     });
 
 var context = g.append("g")
@@ -34,33 +47,98 @@ context.append("g")
 context.append("g")
   .attr("class", "x brush")
 
-var events = [];
-d3.json("/events.json", function(error, data) {
-    data.forEach(function(d) {
-        d.timestamp = d3.time.format.iso.parse(d.timestamp);
-    });
-    events = data;
+// API token, if the server requires one. It can be passed once in the
+// address as "#token=..." and is remembered for later visits.
+var token = (function() {
+    var m = /token=([^&]+)/.exec(window.location.hash);
+    if(m) {
+        localStorage.setItem("token", m[1]);
+        window.location.hash = "";
+    }
+    return localStorage.getItem("token");
+})();
+
+// Event counts by actor & repo, and the time range of all events.
+var counts = [];
+var timeRange = [];
+
+fetchJSON("/stats?interval=day", function(error, days) {
+    days = days || [];
+
+    // Set domain from the first & last day with events.
+    if(days.length > 0) {
+        var first = d3.time.format.iso.parse(days[0].time),
+            last  = d3.time.format.iso.parse(days[days.length-1].time);
+        timeRange = [first, d3.time.day.offset(last, 1)];
+        x.domain(timeRange);
+    }
+
+    fetchJSON("/stats?group=actor,repo", function(error, data) {
+        counts = data || [];
+        refresh(counts);
 
-    refresh();
+        // Count new events as they arrive.
+        stream();
+    });
 });
 
+// Opens a stream of new events from the server. Events are counted on the
+// chart unless a time range has been selected with the brush.
+function stream() {
+    var url = "/events/stream";
+    if(token) {
+        url += "?access_token=" + encodeURIComponent(token);
+    }
+
+    var source = new EventSource(url);
+    source.onmessage = function(msg) {
+        var e = parseEvents([JSON.parse(msg.data)])[0];
+
+        // Increment the count for the event's actor & repo.
+        var count = counts.filter(function(d) {
+            return d.actor == e.actor && d.repository == e.repository;
+        })[0];
+        if(count == null) {
+            count = {actor: e.actor, repository: e.repository, count: 0};
+            counts.push(count);
+        }
+        count.count++;
+
+        // Extend the domain to include the event.
+        timeRange = d3.extent(timeRange.concat([e.timestamp]));
+        x.domain(timeRange);
 
-function refresh() {
-    // Extract min/max timestamps and set domain.
-    x.domain(d3.extent(events.map(function(d) {
-        return d.timestamp;
-    })));
+        if(brush.empty()) {
+            refresh(counts);
+        }
+    };
+}
 
+// Fetches JSON from the API, sending the token if there is one.
+function fetchJSON(url, callback) {
+    var xhr = d3.json(url);
+    if(token) {
+        xhr.header("Authorization", "Bearer " + token);
+    }
+    xhr.get(callback);
+}
+
+// Converts timestamps on a list of events returned from the server.
+function parseEvents(data) {
+    data = data || [];
+    data.forEach(function(d) {
+        d.timestamp = d3.time.format.iso.parse(d.timestamp);
+    });
+    return data;
+}
+
+// Redraws the chart from a list of event counts by actor & repo.
+function refresh(counts) {
     // Constructor actor/repo hierarchy.
     var reposByActor = {id: 0, children:[], lookup: {}};
     var id = 1;
-    for(var i=0; i<events.length; i++) {
-        var e = events[i];
-
-        // If brush exists, filter by extent.
-        if(!brush.empty() && (e.timestamp.valueOf() < brush.extent()[0].valueOf() || e.timestamp.valueOf() > brush.extent()[1].valueOf())) {
-            continue;
-        }
+    for(var i=0; i<counts.length; i++) {
+        var e = counts[i];
 
         // Create actor lookup.
         if(reposByActor.lookup[e.actor] == null) {
@@ -77,8 +155,8 @@ function refresh() {
             actor.lookup[e.repository] = repo
         }
 
-        // Increment value of repo.        
-        actor.lookup[e.repository].value++;
+        // Add count to value of repo.
+        actor.lookup[e.repository].value += e.count;
     }
 
     g.datum(reposByActor).selectAll(".node").data(layout.nodes, function(d) { return d.id.toString(); })
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/benbjohnson/edb"
+)
+
+// RunExport writes events matching the filters to stdout or a file.
+func (m *Main) RunExport(args ...string) error {
+	// Parse command line flags.
+	fs := flag.NewFlagSet("edbd export", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	format := fs.String("format", "ndjson", "output format: csv, ndjson or json")
+	output := fs.String("o", "", "output path, defaults to stdout")
+	actors := fs.String("actors", "", "comma-separated actors")
+	repos := fs.String("repos", "", "comma-separated repositories, e.g. owner/name")
+	types := fs.String("types", "", "comma-separated event types")
+	usernames := fs.String("usernames", "", "comma-separated usernames")
+	since := fs.String("since", "", "start time, in RFC 3339 format")
+	until := fs.String("until", "", "end time (exclusive), in RFC 3339 format")
+	payload := fs.Bool("payload", true, "include raw event payloads")
+	columns := fs.String("columns", "", "comma-separated payload fields added as csv columns, e.g. pull_request.number")
+	fs.Usage = func() {
+		fmt.Fprintln(m.Stderr, "usage: edbd export -config PATH [options]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *format != "csv" && *format != "ndjson" && *format != "json" {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	// Build query from filters.
+	q := &edb.Query{
+		Actors:       splitList(*actors),
+		Repositories: splitList(*repos),
+		Types:        splitList(*types),
+		Usernames:    splitList(*usernames),
+	}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid since: %s", *since)
+		}
+		q.Since = t
+	}
+	if *until != "" {
+		t, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("invalid until: %s", *until)
+		}
+		q.Until = t
+	}
+
+	config, err := m.loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Open database.
+	if err := m.openCommandDB(config.DataPath); err != nil {
+		return err
+	}
+
+	// Open output file, if specified.
+	var w io.Writer = m.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	// Create encoder for format.
+	var enc edb.EventEncoder
+	switch *format {
+	case "csv":
+		csvEnc := edb.NewCSVEncoder(bw)
+		csvEnc.PayloadColumns = splitList(*columns)
+		csvEnc.OmitPayload = !*payload
+		enc = csvEnc
+	case "ndjson":
+		enc = edb.NewNDJSONEncoder(bw)
+	case "json":
+		enc = edb.NewJSONEncoder(bw)
+	}
+
+	// Write events.
+	var n int
+	if err := m.DB.ForEach(q, func(e *edb.Event) error {
+		if *format != "csv" && !*payload {
+			e.Payload = nil
+		}
+		n++
+		return enc.Encode(e)
+	}); err != nil {
+		return err
+	} else if err := enc.Close(); err != nil {
+		return err
+	} else if err := bw.Flush(); err != nil {
+		return err
+	}
+	m.logger.Printf("exported %d events", n)
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/benbjohnson/edb"
+)
+
+var (
+	ErrImportPathRequired = errors.New("archive path required")
+
+	ErrImportFilterRequired = errors.New("usernames or repositories required")
+)
+
+// DefaultImportBatchSize is the default number of events saved per transaction.
+const DefaultImportBatchSize = 1000
+
+// RunImportGHArchive imports events from GH Archive hourly dumps on disk.
+// Only events by the configured GitHub usernames, or on the given
+// repositories, are imported. Events already in the database are skipped.
+func (m *Main) RunImportGHArchive(args ...string) error {
+	// Parse command line flags.
+	fs := flag.NewFlagSet("edbd import-gharchive", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	usernames := fs.String("usernames", "", "comma-separated usernames, defaults to github usernames in config")
+	repos := fs.String("repos", "", "comma-separated repositories, e.g. owner/name")
+	batchSize := fs.Int("batch-size", DefaultImportBatchSize, "events saved per transaction")
+	fs.Usage = func() {
+		fmt.Fprintln(m.Stderr, "usage: edbd import-gharchive -config PATH [options] FILE.json.gz...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return ErrImportPathRequired
+	}
+
+	config, err := m.loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Build filter from flags, falling back to configured usernames.
+	filter := ghArchiveFilter{
+		usernames: splitList(*usernames),
+		repos:     splitList(*repos),
+	}
+	if len(filter.usernames) == 0 && len(filter.repos) == 0 {
+		filter.usernames = config.GitHub.Usernames
+	}
+	if len(filter.usernames) == 0 && len(filter.repos) == 0 {
+		return ErrImportFilterRequired
+	}
+
+	// Open database.
+	if err := m.openCommandDB(config.DataPath); err != nil {
+		return err
+	}
+
+	// Import each file and report totals.
+	var total ghArchiveStats
+	for _, path := range fs.Args() {
+		stats, err := m.importGHArchiveFile(path, &filter, *batchSize)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		m.logger.Printf("%s: scanned=%d imported=%d duplicates=%d", path, stats.scanned, stats.imported, stats.duplicates)
+
+		total.scanned += stats.scanned
+		total.imported += stats.imported
+		total.duplicates += stats.duplicates
+	}
+	fmt.Fprintf(m.Stdout, "imported %d events from %d files (scanned=%d, duplicates=%d)\n", total.imported, fs.NArg(), total.scanned, total.duplicates)
+
+	return nil
+}
+
+// importGHArchiveFile imports matching events from a single archive file.
+func (m *Main) importGHArchiveFile(path string, filter *ghArchiveFilter, batchSize int) (ghArchiveStats, error) {
+	var stats ghArchiveStats
+
+	f, err := os.Open(path)
+	if err != nil {
+		return stats, err
+	}
+	defer f.Close()
+
+	// Archives are gzipped but allow uncompressed files as well.
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return stats, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// Decode events and save in batches.
+	var batch []edb.Event
+	dec := edb.NewGHArchiveDecoder(r)
+	for {
+		e, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return stats, err
+		}
+		stats.scanned++
+
+		// Skip events we're not interested in or already have.
+		if !filter.match(e) {
+			continue
+		} else if prev, err := m.DB.Event(e.Actor, e.ID); err != nil {
+			return stats, err
+		} else if prev != nil {
+			stats.duplicates++
+			continue
+		}
+
+		batch = append(batch, *e)
+		if len(batch) >= batchSize {
+			if err := m.DB.SaveEvents(batch); err != nil {
+				return stats, err
+			}
+			stats.imported += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	// Save remaining events.
+	if len(batch) > 0 {
+		if err := m.DB.SaveEvents(batch); err != nil {
+			return stats, err
+		}
+		stats.imported += len(batch)
+	}
+
+	return stats, nil
+}
+
+// ghArchiveFilter matches events by actor or repository.
+type ghArchiveFilter struct {
+	usernames []string
+	repos     []string
+}
+
+func (f *ghArchiveFilter) match(e *edb.Event) bool {
+	for _, username := range f.usernames {
+		if strings.EqualFold(e.Actor, username) {
+			return true
+		}
+	}
+	for _, repo := range f.repos {
+		if strings.EqualFold(e.Repository, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// ghArchiveStats holds counts from an import.
+type ghArchiveStats struct {
+	scanned    int
+	imported   int
+	duplicates int
+}
+
+// splitList splits a comma-separated list, ignoring empty items.
+func splitList(s string) []string {
+	var a []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			a = append(a, v)
+		}
+	}
+	return a
+}
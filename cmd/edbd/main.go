@@ -7,8 +7,11 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"github.com/BurntSushi/toml"
@@ -22,8 +25,20 @@ var (
 	ErrDataPathRequired = errors.New("data path required")
 
 	ErrAccessTokenRequired = errors.New("access token required")
+
+	ErrGitLabURLRequired = errors.New("gitlab url required")
+
+	ErrGiteaURLRequired = errors.New("gitea url required")
+
+	ErrGitPathRequired = errors.New("git repository path required")
+
+	ErrDatabaseInUse = errors.New("database is in use; stop edbd first")
 )
 
+// CommandLockTimeout is the time commands wait for the server to release the
+// database before giving up.
+const CommandLockTimeout = 1 * time.Second
+
 func main() {
 	m := NewMain()
 	defer func() { _ = m.Close() }()
@@ -31,7 +46,6 @@ func main() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	<-(chan struct{})(nil)
 }
 
 // Main represents the main program execution.
@@ -70,10 +84,33 @@ func (m *Main) Close() error {
 	return nil
 }
 
+// Run executes a subcommand if one is named by the first argument.
+// Otherwise the server is started.
 func (m *Main) Run(args ...string) error {
 	// Set up logger.
 	m.logger = log.New(m.Stderr, "", log.LstdFlags)
 
+	// Dispatch to subcommand, if specified.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "import-gharchive":
+			return m.RunImportGHArchive(args[1:]...)
+		case "token":
+			return m.RunToken(args[1:]...)
+		case "export":
+			return m.RunExport(args[1:]...)
+		case "rebuild-rollups":
+			return m.RunRebuildRollups(args[1:]...)
+		default:
+			return fmt.Errorf("unknown command: %s", args[0])
+		}
+	}
+
+	return m.runServer(args)
+}
+
+// runServer starts the fetchers and serves the HTTP interface.
+func (m *Main) runServer(args []string) error {
 	// Parse command line flags and config.
 	config, err := m.parseFlags(args)
 	if err != nil {
@@ -88,14 +125,27 @@ func (m *Main) Run(args ...string) error {
 		return err
 	}
 
-	// Start fetchers.
-	m.startFetchers(config.AccessToken, config.Usernames)
+	// Start fetchers for each source.
+	m.startGitHubFetchers(&config.GitHub)
+	for i := range config.GitLab {
+		m.startGitLabFetchers(&config.GitLab[i])
+	}
+	for i := range config.Gitea {
+		m.startGiteaFetchers(&config.Gitea[i])
+	}
+	for i := range config.Git {
+		m.startGitFetcher(&config.Git[i])
+	}
 
 	// Start HTTP server.
-	h := &edb.Handler{DB: m.DB}
-	m.logger.Print(http.ListenAndServe(":13000", h))
-
-	return nil
+	h := &edb.Handler{
+		DB:            m.DB,
+		WebhookSecret: config.GitHub.WebhookSecret,
+		RequireAuth:   config.HTTP.RequireAuth,
+		PublicAssets:  config.HTTP.PublicAssets,
+		CORS:          config.CORS.policy(),
+	}
+	return http.ListenAndServe(":13000", h)
 }
 
 func (m *Main) parseFlags(args []string) (*Config, error) {
@@ -104,47 +154,239 @@ func (m *Main) parseFlags(args []string) (*Config, error) {
 	configPath := fs.String("config", "", "config path")
 	if err := fs.Parse(args); err != nil {
 		return nil, err
-	} else if *configPath == "" {
+	}
+	return m.loadConfig(*configPath)
+}
+
+// loadConfig parses and validates the configuration file at path.
+func (m *Main) loadConfig(path string) (*Config, error) {
+	if path == "" {
 		return nil, ErrConfigRequired
 	}
 
 	// Parse configuration.
 	var config Config
-	if _, err := toml.DecodeFile(*configPath, &config); err != nil {
+	if _, err := toml.DecodeFile(path, &config); err != nil {
 		return nil, err
-	} else if config.DataPath == "" {
+	}
+	config.normalize()
+
+	// Validate configuration.
+	if config.DataPath == "" {
 		return nil, ErrDataPathRequired
-	} else if config.AccessToken == "" {
+	} else if len(config.GitHub.Usernames) > 0 && config.GitHub.AccessToken == "" {
 		return nil, ErrAccessTokenRequired
 	}
+	for _, c := range config.GitLab {
+		if c.URL == "" {
+			return nil, ErrGitLabURLRequired
+		} else if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid gitlab url: %q", c.URL)
+		}
+	}
+	for _, c := range config.Gitea {
+		if c.URL == "" {
+			return nil, ErrGiteaURLRequired
+		} else if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid gitea url: %q", c.URL)
+		}
+	}
+	for _, c := range config.Git {
+		if c.Path == "" {
+			return nil, ErrGitPathRequired
+		}
+	}
 
 	return &config, nil
 }
 
-// startFetchers starts a fetcher for each of the users.
-func (m *Main) startFetchers(accessToken string, usernames []string) {
+// openCommandDB opens the database for a command other than the server. The
+// server holds an exclusive lock on the database so it must be stopped first.
+func (m *Main) openCommandDB(path string) error {
+	m.DB = edb.NewDB()
+	m.DB.LockTimeout = CommandLockTimeout
+	if err := m.DB.Open(path); err == edb.ErrDatabaseLocked {
+		return ErrDatabaseInUse
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// startGitHubFetchers starts a fetcher for each of the GitHub users.
+func (m *Main) startGitHubFetchers(c *GitHubConfig) {
+	if len(c.Usernames) == 0 {
+		return
+	}
+
 	// Create GitHub client.
-	t := &oauth.Transport{Token: &oauth.Token{AccessToken: accessToken}}
+	t := &oauth.Transport{Token: &oauth.Token{AccessToken: c.AccessToken}}
 	client := github.NewClient(t.Client())
 
-	m.logger.Printf("starting fetchers(%d)", len(usernames))
+	m.logger.Printf("starting github fetchers(%d)", len(c.Usernames))
+
+	// Share a scheduler so all fetchers stay within the client's rate limit.
+	scheduler := edb.NewGitHubScheduler()
+
+	// Start a fetcher for each username, spreading them over the interval.
+	for i, username := range c.Usernames {
+		src := edb.NewGitHubSource(client, m.DB, username)
+		src.Scheduler = scheduler
+		src.Logger = m.logger
+
+		f := edb.NewFetcher("github/"+username, src, m.DB)
+		f.Offset = time.Duration(i) * f.Interval / time.Duration(len(c.Usernames))
+		m.startFetcher(f)
+	}
+}
+
+// startGitLabFetchers starts a fetcher for each of the users on a GitLab instance.
+func (m *Main) startGitLabFetchers(c *GitLabConfig) {
+	m.logger.Printf("starting gitlab fetchers(%d): %s", len(c.Usernames), c.URL)
+
+	u, _ := url.Parse(c.URL)
+	for i, username := range c.Usernames {
+		src := edb.NewGitLabSource(c.URL, c.AccessToken, username)
+		src.Logger = m.logger
+
+		f := edb.NewFetcher("gitlab/"+u.Host+"/"+username, src, m.DB)
+		f.Offset = time.Duration(i) * f.Interval / time.Duration(len(c.Usernames))
+		m.startFetcher(f)
+	}
+}
+
+// startGiteaFetchers starts a fetcher for each of the users on a Gitea instance.
+func (m *Main) startGiteaFetchers(c *GiteaConfig) {
+	m.logger.Printf("starting gitea fetchers(%d): %s", len(c.Usernames), c.URL)
 
-	// Start a fetcher for each username
-	for _, username := range usernames {
-		f := edb.NewGitHubFetcher(client, m.DB, username)
-		f.Logger = m.logger
+	u, _ := url.Parse(c.URL)
+	for i, username := range c.Usernames {
+		src := edb.NewGiteaSource(c.URL, c.AccessToken, username)
+		src.Logger = m.logger
 
-		m.wg.Add(1)
-		go func() {
-			defer m.wg.Done()
-			f.Run(m.closing)
-		}()
+		f := edb.NewFetcher("gitea/"+u.Host+"/"+username, src, m.DB)
+		f.Offset = time.Duration(i) * f.Interval / time.Duration(len(c.Usernames))
+		m.startFetcher(f)
 	}
 }
 
+// startGitFetcher starts a fetcher which scans a local git repository.
+func (m *Main) startGitFetcher(c *GitConfig) {
+	m.logger.Printf("starting git fetcher: %s", c.Path)
+
+	src := edb.NewGitSource(c.Path)
+	src.Repository = c.Repository
+	src.Authors = c.Authors
+	src.Logger = m.logger
+
+	m.startFetcher(edb.NewFetcher("git/"+c.Path, src, m.DB))
+}
+
+// startFetcher runs a fetcher in a separate goroutine until Main is closed.
+func (m *Main) startFetcher(f *edb.Fetcher) {
+	f.Logger = m.logger
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		f.Run(m.closing)
+	}()
+}
+
 // Config represents the application configuration format.
 type Config struct {
+	DataPath string `toml:"data-path"`
+
+	HTTP HTTPConfig `toml:"http"`
+	CORS CORSConfig `toml:"cors"`
+
+	// Sources of events.
+	GitHub GitHubConfig   `toml:"github"`
+	GitLab []GitLabConfig `toml:"gitlab"`
+	Gitea  []GiteaConfig  `toml:"gitea"`
+	Git    []GitConfig    `toml:"git"`
+
+	// Deprecated top-level GitHub settings. Use the [github] section instead.
 	AccessToken string   `toml:"access-token"`
-	DataPath    string   `toml:"data-path"`
 	Usernames   []string `toml:"usernames"`
 }
+
+// normalize moves deprecated settings into their source sections.
+func (c *Config) normalize() {
+	if c.GitHub.AccessToken == "" {
+		c.GitHub.AccessToken = c.AccessToken
+	}
+	c.GitHub.Usernames = append(c.GitHub.Usernames, c.Usernames...)
+}
+
+// HTTPConfig represents the configuration of the HTTP interface.
+type HTTPConfig struct {
+	// Requires an API token for all requests except webhooks.
+	// Tokens are managed with "edbd token".
+	RequireAuth bool `toml:"require-auth"`
+
+	// Serves the dashboard without a token.
+	PublicAssets bool `toml:"public-assets"`
+}
+
+// CORSConfig represents the policy for cross-origin requests.
+// Any origin is allowed if "allowed-origins" is not set. Set it to an
+// empty list to deny all cross-origin requests.
+type CORSConfig struct {
+	AllowedOrigins []string `toml:"allowed-origins"`
+	AllowedMethods []string `toml:"allowed-methods"`
+	AllowedHeaders []string `toml:"allowed-headers"`
+
+	// Preflight cache duration, in seconds.
+	MaxAge int `toml:"max-age"`
+}
+
+// policy returns the handler's CORS policy. Returns nil if no origins are allowed.
+func (c *CORSConfig) policy() *edb.CORS {
+	origins := c.AllowedOrigins
+	if origins == nil {
+		origins = []string{"*"}
+	} else if len(origins) == 0 {
+		return nil
+	}
+
+	return &edb.CORS{
+		AllowedOrigins: origins,
+		AllowedMethods: c.AllowedMethods,
+		AllowedHeaders: c.AllowedHeaders,
+		MaxAge:         time.Duration(c.MaxAge) * time.Second,
+	}
+}
+
+// GitHubConfig represents the configuration for fetching GitHub events.
+type GitHubConfig struct {
+	AccessToken string   `toml:"access-token"`
+	Usernames   []string `toml:"usernames"`
+
+	// Secret for verifying deliveries to /webhooks/github.
+	WebhookSecret string `toml:"webhook-secret"`
+}
+
+// GitLabConfig represents the configuration for fetching events from a GitLab instance.
+type GitLabConfig struct {
+	URL         string   `toml:"url"`
+	AccessToken string   `toml:"access-token"`
+	Usernames   []string `toml:"usernames"`
+}
+
+// GiteaConfig represents the configuration for fetching activities from a
+// Gitea or Forgejo instance.
+type GiteaConfig struct {
+	URL         string   `toml:"url"`
+	AccessToken string   `toml:"access-token"`
+	Usernames   []string `toml:"usernames"`
+}
+
+// GitConfig represents the configuration for scanning a local git repository.
+type GitConfig struct {
+	Path       string `toml:"path"`
+	Repository string `toml:"repository"`
+
+	// Maps author emails to actor names.
+	Authors map[string]string `toml:"authors"`
+}
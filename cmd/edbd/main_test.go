@@ -2,10 +2,17 @@ package main_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/benbjohnson/edb"
 	"github.com/benbjohnson/edb/cmd/edbd"
 )
 
@@ -17,6 +24,218 @@ func TestMain(t *testing.T) {
 	}
 }
 
+// Ensure GitLab & Gitea URLs must include a scheme and host.
+func TestMain_ErrInvalidURL(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	for i, tt := range []struct {
+		config string
+		err    string
+	}{
+		{config: "[[gitlab]]\nurl = \"gitlab.example.com\"", err: `invalid gitlab url: "gitlab.example.com"`},
+		{config: "[[gitlab]]\nurl = \"https://\"", err: `invalid gitlab url: "https://"`},
+		{config: "[[gitea]]\nurl = \"/gitea\"", err: `invalid gitea url: "/gitea"`},
+	} {
+		configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`data-path = "`+filepath.Join(dir, "db")+`"`+"\n"+tt.config))
+
+		m := NewMain()
+		if err := m.Run("-config", configPath); err == nil || err.Error() != tt.err {
+			t.Errorf("%d. unexpected error: %v", i, err)
+		}
+		m.Close()
+	}
+}
+
+// Ensure events for configured users can be imported from GH Archive dumps.
+func TestMain_ImportGHArchive(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`
+data-path = "`+filepath.Join(dir, "db")+`"
+
+[github]
+access-token = "XXX"
+usernames = ["bob"]
+`))
+	archivePath := MustWriteGzipFile(filepath.Join(dir, "2015-01-01-15.json.gz"), []byte(
+		`{"id":"1","type":"PushEvent","actor":{"login":"bob"},"repo":{"name":"bob/foo"},"payload":{},"created_at":"2015-01-01T15:00:00Z"}
+{"id":"2","type":"PushEvent","actor":{"login":"susy"},"repo":{"name":"susy/bar"},"payload":{},"created_at":"2015-01-01T15:00:01Z"}
+{"id":"3","type":"IssuesEvent","actor":{"login":"bob"},"repo":{"name":"susy/bar"},"payload":{},"created_at":"2015-01-01T15:00:02Z"}
+`))
+
+	// Import the archive.
+	m := NewMain()
+	if err := m.Run("import-gharchive", "-config", configPath, "-batch-size", "1", archivePath); err != nil {
+		t.Fatal(err)
+	} else if a, err := m.DB.Events(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].ID != "1" || a[1].ID != "3" {
+		t.Fatalf("unexpected events: %#v", a)
+	}
+	m.Close()
+
+	// Importing again skips duplicates.
+	m = NewMain()
+	defer m.Close()
+	if err := m.Run("import-gharchive", "-config", configPath, archivePath); err != nil {
+		t.Fatal(err)
+	} else if s := m.Stdout.String(); !strings.Contains(s, "imported 0 events") || !strings.Contains(s, "duplicates=2") {
+		t.Fatalf("unexpected output: %s", s)
+	}
+}
+
+// Ensure API tokens can be created, listed and revoked.
+func TestMain_Token(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`data-path = "`+filepath.Join(dir, "db")+`"`))
+
+	// Create a token.
+	m := NewMain()
+	if err := m.Run("token", "create", "-config", configPath, "-name", "dashboard", "-scopes", "read,ingest"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(m.Stdout.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "(read,ingest)") {
+		t.Fatalf("unexpected output: %s", m.Stdout.String())
+	}
+	id := strings.Fields(lines[0])[2]
+	if tok, err := m.DB.Authenticate(lines[1]); err != nil {
+		t.Fatal(err)
+	} else if tok == nil || tok.ID != id {
+		t.Fatalf("unexpected token: %#v", tok)
+	}
+	m.Close()
+
+	// List tokens.
+	m = NewMain()
+	if err := m.Run("token", "list", "-config", configPath); err != nil {
+		t.Fatal(err)
+	} else if s := m.Stdout.String(); !strings.Contains(s, id) || !strings.Contains(s, "dashboard") {
+		t.Fatalf("unexpected output: %s", s)
+	}
+	m.Close()
+
+	// Revoke the token.
+	m = NewMain()
+	defer m.Close()
+	if err := m.Run("token", "revoke", "-config", configPath, id); err != nil {
+		t.Fatal(err)
+	} else if a, err := m.DB.Tokens(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected tokens: %#v", a)
+	}
+}
+
+// Ensure filtered events can be exported in each format.
+func TestMain_Export(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`data-path = "`+filepath.Join(dir, "db")+`"`))
+
+	// Save events.
+	db := edb.NewDB()
+	if err := db.Open(filepath.Join(dir, "db")); err != nil {
+		t.Fatal(err)
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "IssuesEvent", Timestamp: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), Actor: "bob", Repository: "bob/foo"},
+		{ID: "3", Type: "PushEvent", Timestamp: time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC), Actor: "susy", Repository: "susy/bar"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	for i, tt := range []struct {
+		args []string
+		exp  string
+	}{
+		{
+			args: []string{"-format", "csv", "-types", "PushEvent", "-payload=false"},
+			exp:  "id,type,timestamp,username,actor,repository,org\r\n1,PushEvent,2000-01-01T00:00:00Z,,bob,bob/foo,\r\n3,PushEvent,2000-01-03T00:00:00Z,,susy,susy/bar,\r\n",
+		},
+		{
+			args: []string{"-format", "ndjson", "-actors", "susy"},
+			exp:  `{"id":"3","type":"PushEvent","timestamp":"2000-01-03T00:00:00Z","username":"","actor":"susy","repository":"susy/bar"}` + "\n",
+		},
+		{
+			args: []string{"-format", "json", "-since", "2000-01-02T00:00:00Z", "-until", "2000-01-03T00:00:00Z"},
+			exp:  "[\n" + `{"id":"2","type":"IssuesEvent","timestamp":"2000-01-02T00:00:00Z","username":"","actor":"bob","repository":"bob/foo"}` + "\n]\n",
+		},
+	} {
+		m := NewMain()
+		if err := m.Run(append([]string{"export", "-config", configPath}, tt.args...)...); err != nil {
+			t.Fatalf("%d. unexpected error: %s", i, err)
+		} else if s := m.Stdout.String(); s != tt.exp {
+			t.Errorf("%d. unexpected output: %q", i, s)
+		}
+		m.Close()
+	}
+}
+
+// Ensure daily rollups can be rebuilt.
+func TestMain_RebuildRollups(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`data-path = "`+filepath.Join(dir, "db")+`"`))
+
+	db := edb.NewDB()
+	if err := db.Open(filepath.Join(dir, "db")); err != nil {
+		t.Fatal(err)
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	m := NewMain()
+	defer m.Close()
+	if err := m.Run("rebuild-rollups", "-config", configPath); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(m.Stdout.String(), "rebuilt rollups") {
+		t.Fatalf("unexpected output: %s", m.Stdout.String())
+	} else if a, err := m.DB.Aggregate(&edb.Aggregation{Interval: edb.IntervalDay}); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Count != 2 {
+		t.Fatalf("unexpected groups: %+v", a)
+	}
+}
+
+// Ensure commands fail instead of blocking while the server has the database open.
+func TestMain_ErrDatabaseInUse(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+
+	configPath := MustWriteFile(filepath.Join(dir, "edbd.conf"), []byte(`data-path = "`+filepath.Join(dir, "db")+`"`))
+
+	db := edb.NewDB()
+	if err := db.Open(filepath.Join(dir, "db")); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i, args := range [][]string{
+		{"token", "list", "-config", configPath},
+		{"import-gharchive", "-config", configPath, "-usernames", "bob", filepath.Join(dir, "2015-01-01-15.json.gz")},
+		{"export", "-config", configPath},
+		{"rebuild-rollups", "-config", configPath},
+	} {
+		m := NewMain()
+		if err := m.Run(args...); err != main.ErrDatabaseInUse {
+			t.Errorf("%d. unexpected error: %v", i, err)
+		}
+		m.Close()
+	}
+}
+
 func TestConfig_Parse(t *testing.T) {
 	s := `
 data-path = "/tmp/my.conf"
@@ -37,6 +256,93 @@ usernames = [
 	}
 }
 
+func TestConfig_Parse_GitHub(t *testing.T) {
+	s := `
+data-path = "/tmp/my.conf"
+
+[github]
+access-token = "XXX"
+usernames = ["benbjohnson"]
+`
+
+	var c main.Config
+	if _, err := toml.Decode(s, &c); err != nil {
+		t.Fatal(err)
+	} else if c.GitHub.AccessToken != "XXX" {
+		t.Fatalf("unexpected access token: %s", c.GitHub.AccessToken)
+	} else if !reflect.DeepEqual(c.GitHub.Usernames, []string{"benbjohnson"}) {
+		t.Fatalf("unexpected usernames: %+v", c.GitHub.Usernames)
+	}
+}
+
+func TestConfig_Parse_CORS(t *testing.T) {
+	s := `
+data-path = "/tmp/my.conf"
+
+[cors]
+allowed-origins = ["https://dash.example.com"]
+allowed-headers = ["Authorization"]
+max-age = 600
+`
+
+	var c main.Config
+	if _, err := toml.Decode(s, &c); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(c.CORS.AllowedOrigins, []string{"https://dash.example.com"}) {
+		t.Fatalf("unexpected origins: %+v", c.CORS.AllowedOrigins)
+	} else if !reflect.DeepEqual(c.CORS.AllowedHeaders, []string{"Authorization"}) {
+		t.Fatalf("unexpected headers: %+v", c.CORS.AllowedHeaders)
+	} else if c.CORS.MaxAge != 600 {
+		t.Fatalf("unexpected max age: %d", c.CORS.MaxAge)
+	}
+}
+
+func TestConfig_Parse_GitLab(t *testing.T) {
+	s := `
+data-path = "/tmp/my.conf"
+
+[[gitlab]]
+url = "https://gitlab.example.com"
+access-token = "XXX"
+usernames = ["bob", "susy"]
+`
+
+	var c main.Config
+	if _, err := toml.Decode(s, &c); err != nil {
+		t.Fatal(err)
+	} else if len(c.GitLab) != 1 {
+		t.Fatalf("unexpected gitlab config count: %d", len(c.GitLab))
+	} else if c.GitLab[0].URL != "https://gitlab.example.com" {
+		t.Fatalf("unexpected url: %s", c.GitLab[0].URL)
+	} else if !reflect.DeepEqual(c.GitLab[0].Usernames, []string{"bob", "susy"}) {
+		t.Fatalf("unexpected usernames: %+v", c.GitLab[0].Usernames)
+	}
+}
+
+func TestConfig_Parse_Git(t *testing.T) {
+	s := `
+data-path = "/tmp/my.conf"
+
+[[git]]
+path = "/src/widgets"
+repository = "acme/widgets"
+
+[git.authors]
+"bob@example.com" = "bob"
+`
+
+	var c main.Config
+	if _, err := toml.Decode(s, &c); err != nil {
+		t.Fatal(err)
+	} else if len(c.Git) != 1 {
+		t.Fatalf("unexpected git config count: %d", len(c.Git))
+	} else if c.Git[0].Path != "/src/widgets" || c.Git[0].Repository != "acme/widgets" {
+		t.Fatalf("unexpected config: %+v", c.Git[0])
+	} else if !reflect.DeepEqual(c.Git[0].Authors, map[string]string{"bob@example.com": "bob"}) {
+		t.Fatalf("unexpected authors: %+v", c.Git[0].Authors)
+	}
+}
+
 // Main represents a test wrapper for main.Main.
 type Main struct {
 	*main.Main
@@ -57,3 +363,32 @@ func NewMain() *Main {
 
 	return m
 }
+
+// MustTempDir returns a new temporary directory.
+func MustTempDir() string {
+	path, err := ioutil.TempDir("", "")
+	if err != nil {
+		panic(err.Error())
+	}
+	return path
+}
+
+// MustWriteFile writes b to path and returns the path.
+func MustWriteFile(path string, b []byte) string {
+	if err := ioutil.WriteFile(path, b, 0666); err != nil {
+		panic(err.Error())
+	}
+	return path
+}
+
+// MustWriteGzipFile writes b to path with gzip compression and returns the path.
+func MustWriteGzipFile(path string, b []byte) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		panic(err.Error())
+	} else if err := gz.Close(); err != nil {
+		panic(err.Error())
+	}
+	return MustWriteFile(path, buf.Bytes())
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// RunRebuildRollups regenerates the daily rollups from the stored events.
+func (m *Main) RunRebuildRollups(args ...string) error {
+	fs := flag.NewFlagSet("edbd rebuild-rollups", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	fs.Usage = func() {
+		fmt.Fprintln(m.Stderr, "usage: edbd rebuild-rollups -config PATH")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := m.loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Open database.
+	if err := m.openCommandDB(config.DataPath); err != nil {
+		return err
+	}
+
+	t := time.Now()
+	if err := m.DB.RebuildRollups(); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.Stdout, "rebuilt rollups in %s\n", time.Since(t))
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/benbjohnson/edb"
+)
+
+var (
+	ErrTokenCommandRequired = errors.New("token command required: create, list or revoke")
+
+	ErrTokenIDRequired = errors.New("token id required")
+)
+
+// RunToken manages the API tokens used to authenticate HTTP requests.
+func (m *Main) RunToken(args ...string) error {
+	if len(args) == 0 {
+		return ErrTokenCommandRequired
+	}
+
+	switch args[0] {
+	case "create":
+		return m.runTokenCreate(args[1:])
+	case "list":
+		return m.runTokenList(args[1:])
+	case "revoke":
+		return m.runTokenRevoke(args[1:])
+	default:
+		return fmt.Errorf("unknown token command: %s", args[0])
+	}
+}
+
+// runTokenCreate creates a token and prints its secret.
+func (m *Main) runTokenCreate(args []string) error {
+	fs := flag.NewFlagSet("edbd token create", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	name := fs.String("name", "", "description of the token")
+	scopes := fs.String("scopes", edb.ScopeRead, "comma-separated scopes: read, ingest, admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if err := m.openTokenDB(*configPath); err != nil {
+		return err
+	}
+
+	t, secret, err := m.DB.CreateToken(*name, splitList(*scopes))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(m.Stdout, "created token %s (%s)\n", t.ID, strings.Join(t.Scopes, ","))
+	fmt.Fprintln(m.Stdout, secret)
+	return nil
+}
+
+// runTokenList prints all tokens. Secrets are not available.
+func (m *Main) runTokenList(args []string) error {
+	fs := flag.NewFlagSet("edbd token list", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if err := m.openTokenDB(*configPath); err != nil {
+		return err
+	}
+
+	tokens, err := m.DB.Tokens()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(m.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tCREATED")
+	for _, t := range tokens {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), t.CreatedAt.Format("2006-01-02T15:04:05Z"))
+	}
+	return tw.Flush()
+}
+
+// runTokenRevoke deletes a token by id.
+func (m *Main) runTokenRevoke(args []string) error {
+	fs := flag.NewFlagSet("edbd token revoke", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	configPath := fs.String("config", "", "config path")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return ErrTokenIDRequired
+	} else if err := m.openTokenDB(*configPath); err != nil {
+		return err
+	}
+
+	if err := m.DB.RevokeToken(fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.Stdout, "revoked token %s\n", fs.Arg(0))
+	return nil
+}
+
+// openTokenDB opens the database named by the config file.
+func (m *Main) openTokenDB(configPath string) error {
+	config, err := m.loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	return m.openCommandDB(config.DataPath)
+}
@@ -0,0 +1,88 @@
+package edb
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are the methods allowed for cross-origin requests
+// when none are configured.
+var DefaultCORSMethods = []string{"GET", "POST"}
+
+// DefaultCORSHeaders are the request headers allowed for cross-origin
+// requests when none are configured.
+var DefaultCORSHeaders = []string{"Authorization", "Content-Type"}
+
+// CORS represents the policy for cross-origin requests made by browsers.
+type CORS struct {
+	// Origins allowed to make requests, e.g. "https://example.com".
+	// A "*" allows any origin.
+	AllowedOrigins []string
+
+	// Methods and request headers allowed in cross-origin requests.
+	// Default to DefaultCORSMethods and DefaultCORSHeaders.
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// How long browsers may cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// handle sets the CORS response headers for a request from an allowed origin.
+// Returns true if the request was a preflight request and has been answered.
+func (c *CORS) handle(w http.ResponseWriter, r *http.Request) bool {
+	// Responses differ by origin so caches must key on it.
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" {
+		return false
+	}
+
+	preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+	if !c.allowOrigin(origin) {
+		if preflight {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	// Allow the origin and let it read the paging link.
+	if contains(c.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	w.Header().Set("Access-Control-Expose-Headers", "Link")
+	if !preflight {
+		return false
+	}
+
+	// Answer preflight requests with the allowed methods & headers.
+	methods, headers := c.AllowedMethods, c.AllowedHeaders
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allowOrigin returns true if origin is in the allowed list.
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
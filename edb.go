@@ -1,20 +1,66 @@
 package edb
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
-	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
 )
 
+var (
+	// ErrDatabaseLocked is returned when another process holds the database
+	// open for longer than the lock timeout.
+	ErrDatabaseLocked = errors.New("database locked")
+
+	// ErrInvalidCursor is returned when a query cursor cannot be decoded.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrEventIDRequired is returned when validating an event without an id.
+	ErrEventIDRequired = errors.New("event id required")
+
+	// ErrEventTypeRequired is returned when validating an event without a type.
+	ErrEventTypeRequired = errors.New("event type required")
+
+	// ErrEventTimestampRequired is returned when validating an event without a timestamp.
+	ErrEventTimestampRequired = errors.New("event timestamp required")
+
+	// ErrEventActorRequired is returned when validating an event without an actor.
+	ErrEventActorRequired = errors.New("event actor required")
+
+	// ErrInvalidEventActor is returned when validating an event whose actor
+	// contains a NUL byte, which is reserved as a separator in index keys.
+	ErrInvalidEventActor = errors.New("event actor must not contain NUL")
+
+	// ErrEventTimestampOutOfRange is returned when validating an event whose
+	// timestamp cannot be stored as nanoseconds since 1970, i.e. before 1678
+	// or after 2262.
+	ErrEventTimestampOutOfRange = errors.New("event timestamp out of range")
+)
+
 // DB represents the application level database.
 type DB struct {
 	db *bolt.DB
+
+	// Time to wait for another process to release the database file.
+	// Zero waits indefinitely.
+	LockTimeout time.Duration
+
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
 }
 
+// SubscriptionBufferSize is the number of events buffered for each subscriber.
+const SubscriptionBufferSize = 256
+
 // NewDB returns a new instance of DB.
 func NewDB() *DB {
 	return &DB{}
@@ -22,23 +68,56 @@ func NewDB() *DB {
 
 // Open opens the underlying database.
 func (db *DB) Open(path string) error {
-	d, err := bolt.Open(path, 0666, nil)
-	if err != nil {
+	d, err := bolt.Open(path, 0666, &bolt.Options{Timeout: db.LockTimeout})
+	if err == bolt.ErrTimeout {
+		return ErrDatabaseLocked
+	} else if err != nil {
 		return err
 	}
 	db.db = d
 
 	// Initialize top level buckets.
-	db.db.Update(func(tx *bolt.Tx) error {
-		_, _ = tx.CreateBucketIfNotExists([]byte("events"))
+	if err := db.db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte("events")); err != nil {
+			return err
+		} else if _, err := tx.CreateBucketIfNotExists([]byte("checkpoints")); err != nil {
+			return err
+		} else if _, err := tx.CreateBucketIfNotExists([]byte("tokens")); err != nil {
+			return err
+		}
+
+		// Build the indexes if this database predates any of them or
+		// their current key format.
+		meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
+		if err != nil {
+			return err
+		} else if string(meta.Get([]byte("index-version"))) != indexVersion {
+			return reindex(tx)
+		}
+		for _, name := range indexBuckets {
+			if tx.Bucket([]byte(name)) == nil {
+				return reindex(tx)
+			}
+		}
+
 		return nil
-	})
+	}); err != nil {
+		_ = db.db.Close()
+		return err
+	}
 
 	return nil
 }
 
 // Close closes the underlying database.
 func (db *DB) Close() error {
+	// End all subscriptions.
+	db.mu.Lock()
+	for sub := range db.subs {
+		db.unsubscribe(sub)
+	}
+	db.mu.Unlock()
+
 	if db.db != nil {
 		db.db.Close()
 	}
@@ -46,10 +125,42 @@ func (db *DB) Close() error {
 }
 
 // SaveEvents stores events in the database.
+//
+// Webhook events which duplicate an activity already fetched from the GitHub
+// events API are skipped, and provisional webhook events are replaced when the
+// same activity is fetched from the API.
 func (db *DB) SaveEvents(events []Event) error {
-	return db.db.Update(func(tx *bolt.Tx) error {
+	_, err := db.saveEvents(events)
+	return err
+}
+
+// saveEvents saves events and returns the number written. Events skipped
+// because the same activity is already stored are not counted.
+func (db *DB) saveEvents(events []Event) (int, error) {
+	var n int
+	var added []Event
+	if err := db.db.Update(func(tx *bolt.Tx) error {
 		// Loop over events and insert.
 		for _, e := range events {
+			isNew := true
+
+			// Check for the same activity received from both a webhook and
+			// the events API. The API event is kept. Fingerprints are not
+			// unique within a single source so only compare across sources.
+			if fp := fingerprint(&e); fp != nil {
+				if v := tx.Bucket([]byte("fingerprints")).Get(fp); v != nil {
+					actor, id := splitEventRef(v)
+					if prevWebhook, webhook := strings.HasPrefix(id, WebhookIDPrefix), strings.HasPrefix(e.ID, WebhookIDPrefix); prevWebhook != webhook {
+						if webhook {
+							continue
+						} else if err := deleteEvent(tx, actor, id); err != nil {
+							return err
+						}
+						isNew = false
+					}
+				}
+			}
+
 			// Convert event back to JSON.
 			b, err := json.Marshal(&e)
 			if err != nil {
@@ -63,14 +174,146 @@ func (db *DB) SaveEvents(events []Event) error {
 				return err
 			}
 
+			// Remove the index entries for a previous version of the event.
+			if v := bkt.Get([]byte(e.ID)); v != nil {
+				var prev Event
+				if err := json.Unmarshal(v, &prev); err != nil {
+					return err
+				}
+				if err := unindexEvent(tx, &prev); err != nil {
+					return err
+				}
+				isNew = false
+			}
+
 			// Insert event into database.
 			if err := bkt.Put([]byte(e.ID), b); err != nil {
 				return err
 			}
+
+			// Add event to the indexes.
+			if err := indexEvent(tx, &e); err != nil {
+				return err
+			}
+
+			n++
+			if isNew {
+				added = append(added, e)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	// Notify subscribers once the events are committed.
+	db.notify(added)
+	return n, nil
+}
+
+// Subscribe returns a channel which receives new events matching filter after
+// they are saved, and a function to cancel the subscription. Only the actor,
+// repository, type and username fields of filter are used. A nil filter
+// matches all events.
+//
+// Events which overwrite an existing event, or replace a provisional webhook
+// event, are not sent. Saves never wait on subscribers: if a subscriber falls
+// SubscriptionBufferSize events behind then its channel is closed and it must
+// subscribe again, using a cursor query to recover missed events.
+func (db *DB) Subscribe(filter *Query) (<-chan Event, func()) {
+	if filter == nil {
+		filter = &Query{}
+	}
+	sub := &subscription{filter: filter, c: make(chan Event, SubscriptionBufferSize)}
+
+	db.mu.Lock()
+	if db.subs == nil {
+		db.subs = make(map[*subscription]struct{})
+	}
+	db.subs[sub] = struct{}{}
+	db.mu.Unlock()
+
+	return sub.c, func() {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		db.unsubscribe(sub)
+	}
+}
+
+// unsubscribe removes a subscription and closes its channel. Must hold lock.
+func (db *DB) unsubscribe(sub *subscription) {
+	if _, ok := db.subs[sub]; ok {
+		delete(db.subs, sub)
+		close(sub.c)
+	}
+}
+
+// notify sends new events to matching subscribers without blocking.
+func (db *DB) notify(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for sub := range db.subs {
+		for _, e := range events {
+			if !sub.filter.match(&e) {
+				continue
+			}
+
+			select {
+			case sub.c <- e:
+			default:
+				warnf("subscriber too slow, closing subscription")
+				db.unsubscribe(sub)
+			}
+			if _, ok := db.subs[sub]; !ok {
+				break
+			}
 		}
+	}
+}
+
+// subscription represents a subscriber to new events.
+type subscription struct {
+	filter *Query
+	c      chan Event
+}
 
+// Checkpoint returns the value of a named checkpoint. Checkpoints hold ingestion
+// state, such as ETags, that must survive restarts. Returns blank if unset.
+func (db *DB) Checkpoint(name string) (string, error) {
+	var value string
+	err := db.db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket([]byte("checkpoints")).Get([]byte(name)))
 		return nil
 	})
+	return value, err
+}
+
+// SetCheckpoint sets the value of a named checkpoint.
+func (db *DB) SetCheckpoint(name, value string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("checkpoints")).Put([]byte(name), []byte(value))
+	})
+}
+
+// Event returns a single event by actor and id. Returns nil if the event does not exist.
+func (db *DB) Event(actor, id string) (*Event, error) {
+	var e *Event
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("events")).Bucket([]byte(actor))
+		if bkt == nil || bkt.Get([]byte(id)) == nil {
+			return nil
+		}
+
+		var err error
+		e, err = lookupEvent(tx.Bucket([]byte("events")), []byte(actor), []byte(id))
+		return err
+	})
+	return e, err
 }
 
 // EventsByActor returns a list of events from the database for a single actor.
@@ -99,34 +342,356 @@ func (db *DB) EventsByActor(actor string) ([]Event, error) {
 	return events, err
 }
 
-// Events returns a list of all events from the database.
+// EventsByRepository returns a list of events for a single repository in time order.
+func (db *DB) EventsByRepository(repository string) ([]Event, error) {
+	return db.Query(&Query{Repositories: []string{repository}})
+}
+
+// EventsByType returns a list of events of a single type in time order.
+func (db *DB) EventsByType(typ string) ([]Event, error) {
+	return db.Query(&Query{Types: []string{typ}})
+}
+
+// Events returns a list of all events from the database in time order.
 func (db *DB) Events() ([]Event, error) {
+	return db.Query(&Query{})
+}
+
+// Query returns a list of events matching a query in time order.
+func (db *DB) Query(q *Query) ([]Event, error) {
 	var events []Event
 	err := db.db.View(func(tx *bolt.Tx) error {
-		eventBkt := tx.Bucket([]byte("events"))
+		return query(tx, q, func(e *Event) error {
+			events = append(events, *e)
+			return nil
+		})
+	})
+	return events, err
+}
 
-		return eventBkt.ForEach(func(actor, _ []byte) error {
-			// Retrieve actor bucket.
-			bkt := eventBkt.Bucket(actor)
+// ForEach executes fn for each event matching a query in time order. Events
+// are read within a single read transaction, which is held open until fn has
+// been called for the last event, so results are never held in memory.
+// Iteration stops if fn returns an error.
+func (db *DB) ForEach(q *Query, fn func(e *Event) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		return query(tx, q, fn)
+	})
+}
 
-			// Iterate over all events.
-			c := bkt.Cursor()
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				var e Event
-				if err := json.Unmarshal(v, &e); err != nil {
-					return err
-				}
-				events = append(events, e)
+// query iterates over the most selective index and executes fn for every
+// event matching q. Iteration begins at the edge of the time range and stops
+// once the range or limit is exhausted.
+func query(tx *bolt.Tx, q *Query, fn func(e *Event) error) error {
+	eventsBkt := tx.Bucket([]byte("events"))
+
+	// Use the repository or type index when filtering on a single value.
+	// All indexes share the timeline key format so iteration is the same.
+	idx := tx.Bucket([]byte("timeline"))
+	if len(q.Repositories) == 1 {
+		idx = tx.Bucket([]byte("repositories")).Bucket([]byte(q.Repositories[0]))
+	} else if len(q.Types) == 1 {
+		idx = tx.Bucket([]byte("types")).Bucket([]byte(q.Types[0]))
+	}
+	if idx == nil {
+		return nil
+	}
+	c := idx.Cursor()
+
+	// Decode the cursor of the last event seen, if resuming.
+	var after []byte
+	if q.After != "" {
+		b, err := base64.RawURLEncoding.DecodeString(q.After)
+		if err != nil || len(b) < 8 {
+			return ErrInvalidCursor
+		}
+		after = b
+	}
+
+	// Position the cursor at the start of the range & determine direction.
+	var k, v []byte
+	var next func() ([]byte, []byte)
+	if q.Reverse {
+		next = c.Prev
+
+		// Find the exclusive upper bound and start just before it.
+		var bound []byte
+		if !q.Until.IsZero() {
+			bound = timeKey(q.Until)
+		}
+		if after != nil && (bound == nil || bytes.Compare(after, bound) < 0) {
+			bound = after
+		}
+
+		if bound == nil {
+			k, v = c.Last()
+		} else if k, v = c.Seek(bound); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+	} else {
+		next = c.Next
+
+		// Start after the cursor or at the beginning of the range.
+		var since []byte
+		if !q.Since.IsZero() {
+			since = timeKey(q.Since)
+		}
+		if after != nil && (since == nil || bytes.Compare(after, since) >= 0) {
+			if k, v = c.Seek(after); bytes.Equal(k, after) {
+				k, v = c.Next()
 			}
+		} else if since != nil {
+			k, v = c.Seek(since)
+		} else {
+			k, v = c.First()
+		}
+	}
 
-			return nil
+	var n int
+	for ; k != nil; k, v = next() {
+		// Stop once we have left the time range.
+		if !q.Since.IsZero() && bytes.Compare(k[:8], timeKey(q.Since)) < 0 {
+			break
+		} else if !q.Until.IsZero() && bytes.Compare(k[:8], timeKey(q.Until)) >= 0 {
+			break
+		}
+
+		// Filter by actor before retrieving the event.
+		if len(q.Actors) > 0 && !contains(q.Actors, string(v)) {
+			continue
+		}
+
+		// The key holds the actor, which may contain NUL, so skip past it by length.
+		e, err := lookupEvent(eventsBkt, v, k[8+len(v)+1:])
+		if err != nil {
+			return err
+		} else if !q.match(e) {
+			continue
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+
+		// Stop once the limit has been reached.
+		if n++; q.Limit > 0 && n >= q.Limit {
+			break
+		}
+	}
+
+	return nil
+}
+
+// lookupEvent retrieves and decodes a single event by actor & id.
+func lookupEvent(eventsBkt *bolt.Bucket, actor, id []byte) (*Event, error) {
+	bkt := eventsBkt.Bucket(actor)
+	if bkt == nil {
+		return nil, fmt.Errorf("actor not found: %s", actor)
+	}
+
+	v := bkt.Get(id)
+	if v == nil {
+		return nil, fmt.Errorf("event not found: %s/%s", actor, id)
+	}
+
+	var e Event
+	if err := json.Unmarshal(v, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// deleteEvent removes an event and its index entries.
+func deleteEvent(tx *bolt.Tx, actor, id string) error {
+	bkt := tx.Bucket([]byte("events")).Bucket([]byte(actor))
+	if bkt == nil || bkt.Get([]byte(id)) == nil {
+		return nil
+	}
+
+	e, err := lookupEvent(tx.Bucket([]byte("events")), []byte(actor), []byte(id))
+	if err != nil {
+		return err
+	} else if err := unindexEvent(tx, e); err != nil {
+		return err
+	}
+	return bkt.Delete([]byte(id))
+}
+
+// indexBuckets are the top-level buckets holding secondary indexes. The
+// timeline, repository and type indexes map a timeline key to the actor name
+// of the event. The fingerprint index maps an activity fingerprint to an
+// event reference. The rollups bucket holds daily counts.
+var indexBuckets = []string{"timeline", "repositories", "types", "fingerprints", "rollups"}
+
+// indexVersion is the version of the index key format. Indexes are rebuilt
+// when a database is opened with a different version.
+const indexVersion = "3"
+
+// eventRef returns an encoded reference to an event by actor & id.
+func eventRef(e *Event) []byte {
+	return []byte(e.Actor + "\x00" + e.ID)
+}
+
+// splitEventRef returns the actor & id from an encoded event reference.
+func splitEventRef(v []byte) (actor, id string) {
+	if i := bytes.IndexByte(v, 0); i >= 0 {
+		return string(v[:i]), string(v[i+1:])
+	}
+	return "", string(v)
+}
+
+// indexEvent adds an event to the time, repository and type indexes and
+// to the daily rollups.
+func indexEvent(tx *bolt.Tx, e *Event) error {
+	k := timelineKey(e)
+	if err := tx.Bucket([]byte("timeline")).Put(k, []byte(e.Actor)); err != nil {
+		return err
+	}
+
+	for _, idx := range []struct{ name, value string }{
+		{"repositories", e.Repository},
+		{"types", e.Type},
+	} {
+		if idx.value == "" {
+			continue
+		}
+		bkt, err := tx.Bucket([]byte(idx.name)).CreateBucketIfNotExists([]byte(idx.value))
+		if err != nil {
+			return err
+		} else if err := bkt.Put(k, []byte(e.Actor)); err != nil {
+			return err
+		}
+	}
+
+	if fp := fingerprint(e); fp != nil {
+		if err := tx.Bucket([]byte("fingerprints")).Put(fp, eventRef(e)); err != nil {
+			return err
+		}
+	}
+
+	return updateRollups(tx, e, 1)
+}
+
+// unindexEvent removes an event from the time, repository and type indexes
+// and from the daily rollups.
+func unindexEvent(tx *bolt.Tx, e *Event) error {
+	k := timelineKey(e)
+	if err := tx.Bucket([]byte("timeline")).Delete(k); err != nil {
+		return err
+	}
+
+	for _, idx := range []struct{ name, value string }{
+		{"repositories", e.Repository},
+		{"types", e.Type},
+	} {
+		if bkt := tx.Bucket([]byte(idx.name)).Bucket([]byte(idx.value)); bkt != nil {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only remove the fingerprint if it still refers to this event.
+	if fp := fingerprint(e); fp != nil {
+		bkt := tx.Bucket([]byte("fingerprints"))
+		if bytes.Equal(bkt.Get(fp), eventRef(e)) {
+			if err := bkt.Delete(fp); err != nil {
+				return err
+			}
+		}
+	}
+
+	return updateRollups(tx, e, -1)
+}
+
+// reindex rebuilds all indexes from the events bucket.
+func reindex(tx *bolt.Tx) error {
+	for _, name := range indexBuckets {
+		if tx.Bucket([]byte(name)) != nil {
+			if err := tx.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucket([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	eventsBkt := tx.Bucket([]byte("events"))
+	if err := eventsBkt.ForEach(func(actor, _ []byte) error {
+		return eventsBkt.Bucket(actor).ForEach(func(_, v []byte) error {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			return indexEvent(tx, &e)
 		})
-	})
+	}); err != nil {
+		return err
+	}
 
-	// Sort events by timestamp.
-	sort.Sort(Events(events))
+	return tx.Bucket([]byte("meta")).Put([]byte("index-version"), []byte(indexVersion))
+}
 
-	return events, err
+// timelineKey returns the timeline index key for an event. The key is the
+// big-endian timestamp followed by the event reference so keys sort by time
+// and events with the same id & timestamp from different actors are distinct.
+func timelineKey(e *Event) []byte {
+	return append(timeKey(e.Timestamp), eventRef(e)...)
+}
+
+// Range of timestamps which can be encoded as nanoseconds since 1970.
+var (
+	minEventTime = time.Unix(0, math.MinInt64)
+	maxEventTime = time.Unix(0, math.MaxInt64)
+)
+
+// timeKey returns the big-endian encoding of a timestamp. The sign bit is
+// flipped so times before 1970 sort before later times.
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano())^1<<63)
+	return k
+}
+
+// Query represents a filter used to retrieve events from the database.
+type Query struct {
+	// Time range of events. Since is inclusive and Until is exclusive.
+	// A zero time leaves that side of the range unbounded.
+	Since time.Time
+	Until time.Time
+
+	// When set, only events matching one of the values are returned.
+	Actors       []string
+	Repositories []string
+	Types        []string
+	Usernames    []string
+
+	// Maximum number of events returned. Zero returns all matching events.
+	Limit int
+
+	// Returns events newest first, when true.
+	Reverse bool
+
+	// Opaque cursor of the last event previously seen. When set, iteration
+	// resumes with the event immediately following it.
+	After string
+}
+
+// match returns true if the event matches the query's field filters.
+func (q *Query) match(e *Event) bool {
+	if len(q.Actors) > 0 && !contains(q.Actors, e.Actor) {
+		return false
+	} else if len(q.Repositories) > 0 && !contains(q.Repositories, e.Repository) {
+		return false
+	} else if len(q.Types) > 0 && !contains(q.Types, e.Type) {
+		return false
+	} else if len(q.Usernames) > 0 && !contains(q.Usernames, e.Username) {
+		return false
+	}
+	return true
 }
 
 // Event returns a genericized event.
@@ -137,6 +702,34 @@ type Event struct {
 	Username   string    `json:"username"`
 	Actor      string    `json:"actor"`
 	Repository string    `json:"repository"`
+	Org        string    `json:"org,omitempty"`
+
+	// Source-specific event details, stored as received.
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Validate returns an error if a required field is missing.
+func (e *Event) Validate() error {
+	if e.ID == "" {
+		return ErrEventIDRequired
+	} else if e.Type == "" {
+		return ErrEventTypeRequired
+	} else if e.Timestamp.IsZero() {
+		return ErrEventTimestampRequired
+	} else if e.Timestamp.Before(minEventTime) || e.Timestamp.After(maxEventTime) {
+		return ErrEventTimestampOutOfRange
+	} else if e.Actor == "" {
+		return ErrEventActorRequired
+	} else if strings.IndexByte(e.Actor, 0) >= 0 {
+		return ErrInvalidEventActor
+	}
+	return nil
+}
+
+// Cursor returns an opaque position of the event in time order. It can be
+// passed as Query.After to resume iteration after this event.
+func (e *Event) Cursor() string {
+	return base64.RawURLEncoding.EncodeToString(timelineKey(e))
 }
 
 type Events []Event
@@ -153,5 +746,15 @@ func (a Events) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
 
+// contains returns true if a contains s.
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func warn(v ...interface{})              { fmt.Fprintln(os.Stderr, v...) }
 func warnf(msg string, v ...interface{}) { fmt.Fprintf(os.Stderr, msg+"\n", v...) }
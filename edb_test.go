@@ -1,14 +1,83 @@
 package edb_test
 
 import (
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/edb"
+	"github.com/boltdb/bolt"
 )
 
+// Ensure checkpoints can be set and retrieved across reopens.
+func TestDB_Checkpoint(t *testing.T) {
+	path := MustTempFile()
+	db := edb.NewDB()
+	if err := db.Open(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := db.Checkpoint("github/etag/bob"); err != nil {
+		t.Fatal(err)
+	} else if v != "" {
+		t.Fatalf("unexpected value: %s", v)
+	} else if err := db.SetCheckpoint("github/etag/bob", `"abc"`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopen and verify value persisted.
+	db = edb.NewDB()
+	if err := db.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if v, err := db.Checkpoint("github/etag/bob"); err != nil {
+		t.Fatal(err)
+	} else if v != `"abc"` {
+		t.Fatalf("unexpected value: %s", v)
+	}
+}
+
+// Ensure a single event can be retrieved by actor and id.
+func TestDB_Event(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatalf("save events: %s", err)
+	}
+
+	if e, err := db.Event("bob", "1"); err != nil {
+		t.Fatal(err)
+	} else if e == nil || e.Type != "PushEvent" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	if e, err := db.Event("bob", "2"); err != nil {
+		t.Fatal(err)
+	} else if e != nil {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	if e, err := db.Event("susy", "1"); err != nil {
+		t.Fatal(err)
+	} else if e != nil {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+}
+
 // Ensure events can be added and retrieve from the database by actor name.
 func TestDB_EventsByActor(t *testing.T) {
 	db := edb.NewDB()
@@ -32,9 +101,9 @@ func TestDB_EventsByActor(t *testing.T) {
 		t.Fatal(err)
 	} else if len(a) != 2 {
 		t.Fatalf("unexpected event count(bob): %d", len(a))
-	} else if a[0] != (edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}) {
+	} else if !reflect.DeepEqual(a[0], edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}) {
 		t.Fatalf("unexpected event(0): %#v", a[0])
-	} else if a[1] != (edb.Event{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"}) {
+	} else if !reflect.DeepEqual(a[1], edb.Event{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"}) {
 		t.Fatalf("unexpected event(1): %#v", a[1])
 	}
 }
@@ -62,15 +131,470 @@ func TestDB_Events(t *testing.T) {
 		t.Fatal(err)
 	} else if len(a) != 3 {
 		t.Fatalf("unexpected event count(bob): %d", len(a))
-	} else if a[0] != (edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}) {
+	} else if !reflect.DeepEqual(a[0], edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}) {
 		t.Fatalf("unexpected event(0): %#v", a[0])
-	} else if a[1] != (edb.Event{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy"}) {
+	} else if !reflect.DeepEqual(a[1], edb.Event{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy"}) {
 		t.Fatalf("unexpected event(1): %#v", a[1])
-	} else if a[2] != (edb.Event{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"}) {
+	} else if !reflect.DeepEqual(a[2], edb.Event{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"}) {
 		t.Fatalf("unexpected event(2): %#v", a[2])
 	}
 }
 
+// Ensure an event's org and payload are stored.
+func TestDB_SaveEvents_Payload(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Save event with payload.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Org: "acme", Payload: json.RawMessage(`{"size":2,"ref":"refs/heads/master"}`)},
+	}); err != nil {
+		t.Fatalf("save events: %s", err)
+	}
+
+	// Retrieve event.
+	a, err := db.Events()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if a[0].Org != "acme" {
+		t.Fatalf("unexpected org: %s", a[0].Org)
+	} else if string(a[0].Payload) != `{"size":2,"ref":"refs/heads/master"}` {
+		t.Fatalf("unexpected payload: %s", a[0].Payload)
+	}
+}
+
+// Ensure re-saving an event with a new timestamp moves it in the time index.
+func TestDB_SaveEvents_Overwrite(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Save events and then update the first event's timestamp.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatalf("save events: %s", err)
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-03-01T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatalf("resave events: %s", err)
+	}
+
+	// Retrieve events.
+	a, err := db.Events()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if a[0].ID != "2" || a[1].ID != "1" {
+		t.Fatalf("unexpected order: %s, %s", a[0].ID, a[1].ID)
+	}
+}
+
+// Ensure events from different actors with the same id & timestamp are
+// indexed separately.
+func TestDB_SaveEvents_SameIDDifferentActors(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "susy", Repository: "bob/foo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+
+	// Moving bob's event must not remove susy's from the indexes.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].Actor != "susy" || a[1].Actor != "bob" {
+		t.Fatalf("unexpected events: %#v", a)
+	}
+	if a, err := db.EventsByRepository("bob/foo"); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected repository event count: %d", len(a))
+	}
+	if a, err := db.EventsByType("PushEvent"); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected type event count: %d", len(a))
+	}
+}
+
+// Ensure events before 1970 are ordered and queried by time.
+func TestDB_Query_Before1970(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("1969-12-31T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if a, err := db.Query(&edb.Query{Since: MustParseTime("1960-01-01T00:00:00Z"), Until: MustParseTime("2010-01-01T00:00:00Z")}); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure an actor containing a NUL byte doesn't break reading the index.
+func TestDB_SaveEvents_ActorWithNUL(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "x\x00z", Type: "DeployEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "ci\x00x"},
+		{ID: "2", Type: "DeployEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "ci"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].Actor != "ci\x00x" || a[0].ID != "x\x00z" {
+		t.Fatalf("unexpected events: %#v", a)
+	}
+}
+
+// Ensure API events which share a fingerprint are not deduplicated.
+func TestDB_SaveEvents_SameFingerprint(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Creating the same branch twice produces identical payloads.
+	payload := json.RawMessage(`{"ref":"feature","ref_type":"branch"}`)
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "100", Type: "CreateEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: payload},
+		{ID: "200", Type: "CreateEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: payload},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"100", "200"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure opening a database held by another process times out.
+func TestDB_Open_ErrDatabaseLocked(t *testing.T) {
+	path := MustTempFile()
+	db := edb.NewDB()
+	if err := db.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	other := edb.NewDB()
+	other.LockTimeout = 10 * time.Millisecond
+	if err := other.Open(path); err != edb.ErrDatabaseLocked {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the time index is built when opening a database that predates it.
+func TestDB_Open_Reindex(t *testing.T) {
+	path := MustTempFile()
+
+	// Write events using the original bucket layout only.
+	bdb, err := bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bdb.Update(func(tx *bolt.Tx) error {
+		bkt, _ := tx.CreateBucketIfNotExists([]byte("events"))
+		bob, _ := bkt.CreateBucket([]byte("bob"))
+		susy, _ := bkt.CreateBucket([]byte("susy"))
+		_ = bob.Put([]byte("1"), []byte(`{"id":"1","timestamp":"2000-02-01T00:00:00Z","actor":"bob"}`))
+		_ = susy.Put([]byte("2"), []byte(`{"id":"2","timestamp":"2000-01-01T00:00:00Z","actor":"susy"}`))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bdb.Close()
+
+	// Reopen with edb and verify events come back in time order.
+	db := edb.NewDB()
+	if err := db.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a, err := db.Events()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if a[0].ID != "2" || a[1].ID != "1" {
+		t.Fatalf("unexpected order: %s, %s", a[0].ID, a[1].ID)
+	}
+}
+
+// Ensure events can be retrieved by time range and field filters.
+func TestDB_Query(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Save events.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob", Repository: "bob/bar"},
+		{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy", Repository: "bob/foo"},
+		{ID: "4", Type: "PushEvent", Timestamp: MustParseTime("2000-03-01T00:00:00Z"), Actor: "susy", Repository: "susy/baz"},
+	}); err != nil {
+		t.Fatalf("save events: %s", err)
+	}
+
+	for i, tt := range []struct {
+		q   edb.Query
+		ids []string
+	}{
+		{q: edb.Query{}, ids: []string{"1", "3", "2", "4"}},
+		{q: edb.Query{Since: MustParseTime("2000-01-06T00:00:00Z")}, ids: []string{"3", "2", "4"}},
+		{q: edb.Query{Until: MustParseTime("2000-02-01T00:00:00Z")}, ids: []string{"1", "3"}},
+		{q: edb.Query{Since: MustParseTime("2000-01-02T00:00:00Z"), Until: MustParseTime("2000-03-01T00:00:00Z")}, ids: []string{"3", "2"}},
+		{q: edb.Query{Limit: 2}, ids: []string{"1", "3"}},
+		{q: edb.Query{Limit: 2, Reverse: true}, ids: []string{"4", "2"}},
+		{q: edb.Query{Until: MustParseTime("2000-02-01T00:00:00Z"), Reverse: true}, ids: []string{"3", "1"}},
+		{q: edb.Query{Actors: []string{"susy"}}, ids: []string{"3", "4"}},
+		{q: edb.Query{Repositories: []string{"bob/foo"}}, ids: []string{"1", "3"}},
+		{q: edb.Query{Types: []string{"PushEvent", "IssueEvent"}, Limit: 2}, ids: []string{"1", "2"}},
+		{q: edb.Query{Since: MustParseTime("2001-01-01T00:00:00Z")}, ids: nil},
+	} {
+		a, err := db.Query(&tt.q)
+		if err != nil {
+			t.Fatalf("%d. query: %s", i, err)
+		} else if ids := EventIDs(a); !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%d. unexpected ids: %v", i, ids)
+		}
+	}
+}
+
+// Ensure events can be retrieved by repository and type indexes.
+func TestDB_EventsByRepository(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Save events and move one to a different repository.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "susy", Repository: "bob/foo"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+	}); err != nil {
+		t.Fatalf("save events: %s", err)
+	} else if err := db.SaveEvents([]edb.Event{
+		{ID: "3", Type: "PullRequestEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "bob", Repository: "bob/bar"},
+	}); err != nil {
+		t.Fatalf("resave events: %s", err)
+	}
+
+	if a, err := db.EventsByRepository("bob/foo"); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Fatalf("unexpected ids(bob/foo): %v", ids)
+	}
+
+	if a, err := db.EventsByType("PushEvent"); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Fatalf("unexpected ids(PushEvent): %v", ids)
+	}
+
+	if a, err := db.EventsByType("PullRequestEvent"); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"3"}) {
+		t.Fatalf("unexpected ids(PullRequestEvent): %v", ids)
+	}
+
+	if a, err := db.EventsByRepository("no/such"); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+}
+
+// Ensure a query can resume iteration after a cursor in either direction.
+func TestDB_Query_After(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Save events.
+	events := []edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "susy"},
+		{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "bob"},
+	}
+	if err := db.SaveEvents(events); err != nil {
+		t.Fatalf("save events: %s", err)
+	}
+
+	if a, err := db.Query(&edb.Query{After: events[0].Cursor()}); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if a, err := db.Query(&edb.Query{After: events[2].Cursor(), Reverse: true}); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if _, err := db.Query(&edb.Query{After: "!"}); err != edb.ErrInvalidCursor {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure events can be iterated over until the callback returns an error.
+func TestDB_ForEach(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-03T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	errStop := errors.New("stop")
+	if err := db.ForEach(&edb.Query{}, func(e *edb.Event) error {
+		if ids = append(ids, e.ID); len(ids) == 2 {
+			return errStop
+		}
+		return nil
+	}); err != errStop {
+		t.Fatalf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure subscribers receive new matching events after they are saved.
+func TestDB_Subscribe(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, cancel := db.Subscribe(&edb.Query{Actors: []string{"bob"}})
+
+	// Overwrites and non-matching events are not sent.
+	if err := db.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-03T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-ch; e.ID != "3" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected event: %#v", e)
+	default:
+	}
+
+	// Canceling closes the channel.
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("expected closed channel")
+	}
+	cancel()
+}
+
+// Ensure a subscriber which falls too far behind is closed without blocking saves.
+func TestDB_Subscribe_SlowConsumer(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ch, cancel := db.Subscribe(nil)
+	defer cancel()
+
+	// Save more events than the subscriber can buffer.
+	events := make([]edb.Event, edb.SubscriptionBufferSize+1)
+	for i := range events {
+		events[i] = edb.Event{ID: strconv.Itoa(i), Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}
+	}
+	if err := db.SaveEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	// Buffered events are still received before the channel is closed.
+	var n int
+	for range ch {
+		n++
+	}
+	if n != edb.SubscriptionBufferSize {
+		t.Fatalf("unexpected event count: %d", n)
+	}
+}
+
 // MustTempFile returns a temporary path.
 func MustTempFile() string {
 	f, err := ioutil.TempFile("", "")
@@ -82,6 +606,15 @@ func MustTempFile() string {
 	return f.Name()
 }
 
+// EventIDs returns the ids for a list of events.
+func EventIDs(a []edb.Event) []string {
+	var ids []string
+	for _, e := range a {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
 func MustParseTime(s string) time.Time {
 	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
@@ -0,0 +1,194 @@
+package edb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventEncoder writes a sequence of events in an export format.
+type EventEncoder interface {
+	Encode(e *Event) error
+
+	// Close writes any remaining output. The underlying writer is not closed.
+	Close() error
+}
+
+// NDJSONEncoder writes events as newline-delimited JSON.
+type NDJSONEncoder struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONEncoder returns a new instance of NDJSONEncoder.
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	return &NDJSONEncoder{enc: json.NewEncoder(w)}
+}
+
+// Encode writes an event on a single line.
+func (enc *NDJSONEncoder) Encode(e *Event) error { return enc.enc.Encode(e) }
+
+// Close is a no-op.
+func (enc *NDJSONEncoder) Close() error { return nil }
+
+// JSONEncoder writes events as a JSON array with one event per line.
+type JSONEncoder struct {
+	w io.Writer
+	n int
+}
+
+// NewJSONEncoder returns a new instance of JSONEncoder.
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{w: w}
+}
+
+// Encode writes an event as the next element of the array.
+func (enc *JSONEncoder) Encode(e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if enc.n == 0 {
+		sep = "[\n"
+	}
+	enc.n++
+
+	_, err = io.WriteString(enc.w, sep+string(b))
+	return err
+}
+
+// Close ends the array.
+func (enc *JSONEncoder) Close() error {
+	if enc.n == 0 {
+		_, err := io.WriteString(enc.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(enc.w, "\n]\n")
+	return err
+}
+
+// CSVColumns are the columns written for every event by CSVEncoder.
+var CSVColumns = []string{"id", "type", "timestamp", "username", "actor", "repository", "org", "payload"}
+
+// CSVEncoder writes events as RFC 4180 CSV with a header row.
+type CSVEncoder struct {
+	w      *csv.Writer
+	header bool
+
+	// Payload fields written as additional columns, named "payload.<field>".
+	// Nested fields and array elements are separated by dots,
+	// e.g. "pull_request.number" or "commits.0.sha".
+	PayloadColumns []string
+
+	// Omits the raw payload column, when true.
+	OmitPayload bool
+}
+
+// NewCSVEncoder returns a new instance of CSVEncoder.
+func NewCSVEncoder(w io.Writer) *CSVEncoder {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return &CSVEncoder{w: cw}
+}
+
+// Encode writes an event as a row, preceded by the header if this is the first row.
+func (enc *CSVEncoder) Encode(e *Event) error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+
+	row := []string{e.ID, e.Type, e.Timestamp.Format(time.RFC3339Nano), e.Username, e.Actor, e.Repository, e.Org}
+	if !enc.OmitPayload {
+		row = append(row, compactJSON(e.Payload))
+	}
+
+	// Extract payload fields.
+	if len(enc.PayloadColumns) > 0 {
+		var payload interface{}
+		if len(e.Payload) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(e.Payload))
+			dec.UseNumber()
+			if err := dec.Decode(&payload); err != nil {
+				return err
+			}
+		}
+		for _, column := range enc.PayloadColumns {
+			row = append(row, payloadField(payload, column))
+		}
+	}
+
+	return enc.w.Write(row)
+}
+
+// Close writes the header, if no events were written, and flushes the output.
+func (enc *CSVEncoder) Close() error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+	enc.w.Flush()
+	return enc.w.Error()
+}
+
+// writeHeader writes the header row once.
+func (enc *CSVEncoder) writeHeader() error {
+	if enc.header {
+		return nil
+	}
+	enc.header = true
+
+	header := CSVColumns
+	if enc.OmitPayload {
+		header = header[:len(header)-1]
+	}
+	header = append([]string{}, header...)
+	for _, column := range enc.PayloadColumns {
+		header = append(header, "payload."+column)
+	}
+	return enc.w.Write(header)
+}
+
+// payloadField returns the value at a dot-separated path as a string.
+// Strings are returned as is and other values as JSON. Returns blank if
+// the field does not exist.
+func payloadField(v interface{}, path string) string {
+	for _, key := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v = x[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(x) {
+				return ""
+			}
+			v = x[i]
+		default:
+			return ""
+		}
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// compactJSON returns b with insignificant whitespace removed.
+func compactJSON(b []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return string(b)
+	}
+	return buf.String()
+}
@@ -0,0 +1,69 @@
+package edb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure events are written as CSV with quoting and payload columns.
+func TestCSVEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := edb.NewCSVEncoder(&buf)
+	enc.PayloadColumns = []string{"ref", "pull_request.number", "commits.0.message", "missing"}
+	if err := enc.Encode(&edb.Event{
+		ID:         "1",
+		Type:       "PushEvent",
+		Timestamp:  MustParseTime("2000-01-01T00:00:00Z"),
+		Actor:      "bob",
+		Repository: "bob/foo",
+		Payload:    json.RawMessage(`{"ref": "refs/heads/master", "pull_request": {"number": 12}, "commits": [{"message": "fix \"bug\",\nagain"}]}`),
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "id,type,timestamp,username,actor,repository,org,payload,payload.ref,payload.pull_request.number,payload.commits.0.message,payload.missing\r\n" +
+		`1,PushEvent,2000-01-01T00:00:00Z,,bob,bob/foo,,"{""ref"":""refs/heads/master"",""pull_request"":{""number"":12},""commits"":[{""message"":""fix \""bug\"",\nagain""}]}",refs/heads/master,12,"fix ""bug"",` + "\r\nagain\",\r\n"
+	if buf.String() != exp {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", buf.String(), exp)
+	}
+}
+
+// Ensure the CSV header is written even without events.
+func TestCSVEncoder_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	enc := edb.NewCSVEncoder(&buf)
+	enc.OmitPayload = true
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	} else if s := buf.String(); s != "id,type,timestamp,username,actor,repository,org\r\n" {
+		t.Fatalf("unexpected output: %q", s)
+	}
+}
+
+// Ensure events are written as a valid JSON array.
+func TestJSONEncoder(t *testing.T) {
+	for i, n := range []int{0, 1, 3} {
+		var buf bytes.Buffer
+		enc := edb.NewJSONEncoder(&buf)
+		for j := 0; j < n; j++ {
+			if err := enc.Encode(&edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var a []edb.Event
+		if err := json.Unmarshal(buf.Bytes(), &a); err != nil {
+			t.Fatalf("%d. unexpected error: %s", i, err)
+		} else if len(a) != n {
+			t.Fatalf("%d. unexpected event count: %d", i, len(a))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package edb
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// GHArchiveDecoder decodes events from a GH Archive hourly dump. Dumps are
+// newline-delimited GitHub events API records, usually gzip compressed.
+// Only the post-2015 archive format is supported.
+type GHArchiveDecoder struct {
+	dec *json.Decoder
+}
+
+// NewGHArchiveDecoder returns a decoder which reads uncompressed records from r.
+func NewGHArchiveDecoder(r io.Reader) *GHArchiveDecoder {
+	return &GHArchiveDecoder{dec: json.NewDecoder(r)}
+}
+
+// Decode returns the next event in the archive. Returns io.EOF at the end.
+func (d *GHArchiveDecoder) Decode() (*Event, error) {
+	var rec struct {
+		ID    string `json:"id"`
+		Type  string `json:"type"`
+		Actor struct {
+			Login string `json:"login"`
+		} `json:"actor"`
+		Repo struct {
+			Name string `json:"name"`
+		} `json:"repo"`
+		Org struct {
+			Login string `json:"login"`
+		} `json:"org"`
+		Payload   json.RawMessage `json:"payload"`
+		CreatedAt time.Time       `json:"created_at"`
+	}
+	if err := d.dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:         rec.ID,
+		Type:       rec.Type,
+		Timestamp:  rec.CreatedAt,
+		Username:   rec.Actor.Login,
+		Actor:      rec.Actor.Login,
+		Repository: rec.Repo.Name,
+		Org:        rec.Org.Login,
+		Payload:    rec.Payload,
+	}, nil
+}
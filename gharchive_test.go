@@ -0,0 +1,33 @@
+package edb_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure events can be decoded from a GH Archive dump.
+func TestGHArchiveDecoder_Decode(t *testing.T) {
+	dec := edb.NewGHArchiveDecoder(strings.NewReader(`{"id":"1","type":"PushEvent","actor":{"login":"bob"},"repo":{"name":"bob/foo"},"org":{"login":"acme"},"payload":{"size":1},"public":true,"created_at":"2015-01-01T15:00:00Z"}
+{"id":"2","type":"WatchEvent","actor":{"login":"susy"},"repo":{"name":"bob/foo"},"payload":{},"public":true,"created_at":"2015-01-01T15:00:01Z"}
+`))
+
+	if e, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(e, &edb.Event{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2015-01-01T15:00:00Z"), Username: "bob", Actor: "bob", Repository: "bob/foo", Org: "acme", Payload: []byte(`{"size":1}`)}) {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	if e, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	} else if e.ID != "2" || e.Actor != "susy" || e.Org != "" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("expected EOF: %v", err)
+	}
+}
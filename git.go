@@ -0,0 +1,185 @@
+package edb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitSource scans a local git repository for commits. Commits are identified
+// by SHA so rescanning a repository never produces duplicate events.
+// Its checkpoint is the list of ref tips seen in the previous scan.
+type GitSource struct {
+	// Path to the repository's working tree or git directory.
+	Path string
+
+	// Repository name used for events. Defaults to the origin remote's
+	// path, e.g. "owner/name", or the base name of the repository path.
+	Repository string
+
+	// Maps author emails to actor names, e.g. forge logins. Commits by
+	// unmapped authors use the author name.
+	Authors map[string]string
+
+	Logger *log.Logger
+}
+
+// NewGitSource returns a new GitSource for a repository path.
+func NewGitSource(path string) *GitSource {
+	return &GitSource{
+		Path:   path,
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Fetch returns commits reachable from any ref that were not reachable from
+// the refs recorded in the checkpoint.
+func (s *GitSource) Fetch(closing chan struct{}, checkpoint string) ([]Event, string, error) {
+	// Determine repository name.
+	repository, err := s.repository()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Read current ref tips. An empty repository has none.
+	out, err := s.git("rev-parse", "--all")
+	if err != nil {
+		return nil, "", err
+	}
+	tips := strings.Fields(out)
+	if len(tips) == 0 {
+		return nil, checkpoint, nil
+	}
+
+	// Exclude commits reachable from previous tips which still exist.
+	// Tips removed by a force push are dropped and their replacements rescanned.
+	args := []string{"log", "--all", "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e"}
+	if prev, err := s.existing(strings.Fields(checkpoint)); err != nil {
+		return nil, "", err
+	} else if len(prev) > 0 {
+		args = append(append(args, "--not"), prev...)
+	}
+
+	out, err = s.git(args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Convert each log record to an event.
+	var events []Event
+	for _, rec := range strings.Split(out, "\x1e") {
+		if rec = strings.TrimSpace(rec); rec == "" {
+			continue
+		}
+
+		e, err := s.convert(rec, repository)
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, e)
+	}
+
+	return events, strings.Join(tips, " "), nil
+}
+
+// convert returns an event from a formatted log record.
+func (s *GitSource) convert(rec, repository string) (Event, error) {
+	fields := strings.Split(rec, "\x1f")
+	if len(fields) != 5 {
+		return Event{}, fmt.Errorf("invalid log record: %q", rec)
+	}
+	sha, name, email, date, subject := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	timestamp, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid commit date: %s", date)
+	}
+
+	// Map author onto an actor name.
+	actor := name
+	if v, ok := s.Authors[email]; ok {
+		actor = v
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"sha":          sha,
+		"author_name":  name,
+		"author_email": email,
+		"message":      subject,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:         "git:" + sha,
+		Type:       "CommitEvent",
+		Timestamp:  timestamp.UTC(),
+		Username:   actor,
+		Actor:      actor,
+		Repository: repository,
+		Payload:    payload,
+	}, nil
+}
+
+// existing returns the subset of commit SHAs which still exist in the repository.
+func (s *GitSource) existing(shas []string) ([]string, error) {
+	if len(shas) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.Command("git", "-C", s.Path, "cat-file", "--batch-check")
+	cmd.Stdin = strings.NewReader(strings.Join(shas, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %s", err)
+	}
+
+	var a []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 {
+			a = append(a, fields[0])
+		}
+	}
+	return a, nil
+}
+
+// repository returns the repository name used for events.
+func (s *GitSource) repository() (string, error) {
+	if s.Repository != "" {
+		return s.Repository, nil
+	}
+
+	// Use the last two path segments of the origin remote, if available.
+	if out, err := s.git("config", "--get", "remote.origin.url"); err == nil && strings.TrimSpace(out) != "" {
+		u := strings.TrimSuffix(strings.TrimSpace(out), ".git")
+		u = strings.Replace(u, ":", "/", -1)
+		if segments := strings.Split(u, "/"); len(segments) >= 2 {
+			return strings.Join(segments[len(segments)-2:], "/"), nil
+		}
+	}
+
+	abs, err := filepath.Abs(s.Path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(strings.TrimSuffix(abs, ".git")), nil
+}
+
+// git executes a git command against the repository and returns its output.
+func (s *GitSource) git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", s.Path}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,78 @@
+package edb_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure the git source emits each commit once across rescans.
+func TestGitSource_Fetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	// Create repository with a single commit.
+	path, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	MustGit(path, "", "init", "-q")
+	MustGit(path, "2000-01-01T00:00:00Z", "commit", "-q", "--allow-empty", "-m", "first")
+
+	s := edb.NewGitSource(path)
+	s.Repository = "acme/widgets"
+	s.Authors = map[string]string{"bob@example.com": "bob"}
+	s.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Initial scan returns the first commit.
+	events, checkpoint, err := s.Fetch(make(chan struct{}), "")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(events) != 1 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if e := events[0]; e.Type != "CommitEvent" || e.Actor != "bob" || e.Repository != "acme/widgets" || !e.Timestamp.Equal(MustParseTime("2000-01-01T00:00:00Z")) {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+	first := events[0].ID
+
+	// Rescanning without changes returns nothing.
+	if events, _, err := s.Fetch(make(chan struct{}), checkpoint); err != nil {
+		t.Fatal(err)
+	} else if len(events) != 0 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	}
+
+	// A new commit is returned on its own.
+	MustGit(path, "2000-01-02T00:00:00Z", "commit", "-q", "--allow-empty", "-m", "second")
+	if events, _, err := s.Fetch(make(chan struct{}), checkpoint); err != nil {
+		t.Fatal(err)
+	} else if len(events) != 1 || events[0].ID == first {
+		t.Fatalf("unexpected events: %v", EventIDs(events))
+	}
+
+	// A full rescan produces the same ids as before.
+	if events, _, err := s.Fetch(make(chan struct{}), ""); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(events); len(ids) != 2 || !reflect.DeepEqual(ids[1:], []string{first}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// MustGit runs a git command in path as a fixed author at the given date.
+func MustGit(path, date string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Bob", "GIT_AUTHOR_EMAIL=bob@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Bob", "GIT_COMMITTER_EMAIL=bob@example.com", "GIT_COMMITTER_DATE="+date,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		panic(string(out))
+	}
+}
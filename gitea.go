@@ -0,0 +1,216 @@
+package edb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// GiteaEventsPerPage is the number of activities requested per page.
+	GiteaEventsPerPage = 50
+
+	// GiteaMaxEventPages is the maximum number of pages read per fetch.
+	GiteaMaxEventPages = 10
+)
+
+// GiteaSource fetches new activities performed by a Gitea or Forgejo user.
+// Its checkpoint is the id of the most recent activity seen.
+type GiteaSource struct {
+	// Base URL of the Gitea instance, e.g. "https://gitea.example.com".
+	URL string
+
+	// Access token used to authenticate requests.
+	Token string
+
+	// Username of the user to fetch activities for.
+	Username string
+
+	// HTTP client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	Logger *log.Logger
+}
+
+// NewGiteaSource returns a new GiteaSource for a user on a Gitea instance.
+func NewGiteaSource(u, token, username string) *GiteaSource {
+	return &GiteaSource{
+		URL:      u,
+		Token:    token,
+		Username: username,
+		Client:   http.DefaultClient,
+
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Fetch retrieves activities newer than the checkpoint activity id. Pages are
+// read newest first until an already seen activity is reached.
+func (s *GiteaSource) Fetch(closing chan struct{}, checkpoint string) ([]Event, string, error) {
+	var last int64
+	if checkpoint != "" {
+		n, err := strconv.ParseInt(checkpoint, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid checkpoint: %s", checkpoint)
+		}
+		last = n
+	}
+
+	var events []Event
+	var overlap bool
+	next := last
+	for page := 1; page <= GiteaMaxEventPages; page++ {
+		// Stop if we're shutting down.
+		select {
+		case <-closing:
+			return nil, "", errClosing
+		default:
+		}
+
+		// Retrieve the next page of activities.
+		a, err := s.feeds(page)
+		if err != nil {
+			return nil, "", fmt.Errorf("list activities: %s", err)
+		}
+		s.Logger.Printf("received %d gitea activities (page=%d)", len(a), page)
+
+		// Convert activities until we reach one we already have.
+		for _, act := range a {
+			if act.ID <= last {
+				overlap = true
+				break
+			} else if act.ID > next {
+				next = act.ID
+			}
+			events = append(events, s.convert(act))
+		}
+
+		// Stop if we've caught up or there are no more pages.
+		if overlap || len(a) < GiteaEventsPerPage {
+			break
+		}
+	}
+
+	// Warn if we've fetched before but never reached a previously seen activity.
+	if !overlap && last > 0 && len(events) > 0 {
+		s.Logger.Printf("possible gap in gitea activities for %s: no previously seen activity found in %d new activities", s.Username, len(events))
+	}
+
+	if next == 0 {
+		return events, checkpoint, nil
+	}
+	return events, strconv.FormatInt(next, 10), nil
+}
+
+// feeds returns a page of activities performed by the user.
+func (s *GiteaSource) feeds(page int) ([]*giteaActivity, error) {
+	u := fmt.Sprintf("%s/api/v1/users/%s/activities/feeds?only-performed-by=true&limit=%d&page=%d",
+		strings.TrimSuffix(s.URL, "/"), url.PathEscape(s.Username), GiteaEventsPerPage, page)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "token "+s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var a []*giteaActivity
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status=%d", resp.StatusCode)
+	} else if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// convert returns a generic event from a Gitea activity.
+func (s *GiteaSource) convert(act *giteaActivity) Event {
+	e := Event{
+		ID:        fmt.Sprintf("gitea:%s:%d", s.host(), act.ID),
+		Type:      act.eventType(),
+		Timestamp: act.Created,
+		Username:  s.Username,
+		Payload:   act.raw,
+	}
+	if act.ActUser != nil {
+		e.Actor = act.ActUser.Login
+	}
+	if act.Repo != nil {
+		e.Repository = act.Repo.FullName
+	}
+	return e
+}
+
+// host returns the host name of the Gitea instance.
+func (s *GiteaSource) host() string {
+	if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return s.URL
+}
+
+// giteaActivity represents an entry returned from the Gitea activity feeds API.
+type giteaActivity struct {
+	ID      int64     `json:"id"`
+	OpType  string    `json:"op_type"`
+	Created time.Time `json:"created"`
+	ActUser *struct {
+		Login string `json:"login"`
+	} `json:"act_user"`
+	Repo *struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"`
+
+	// Original JSON of the activity.
+	raw json.RawMessage
+}
+
+// UnmarshalJSON decodes the activity and retains the original JSON.
+func (a *giteaActivity) UnmarshalJSON(b []byte) error {
+	type alias giteaActivity
+	if err := json.Unmarshal(b, (*alias)(a)); err != nil {
+		return err
+	}
+	a.raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// eventType maps the Gitea operation onto GitHub-style event types so
+// events from all services can be charted together. Unknown operations
+// keep their Gitea name.
+func (a *giteaActivity) eventType() string {
+	switch a.OpType {
+	case "commit_repo", "mirror_sync_push":
+		return "PushEvent"
+	case "create_repo", "push_tag", "mirror_sync_create":
+		return "CreateEvent"
+	case "delete_tag", "delete_branch", "mirror_sync_delete":
+		return "DeleteEvent"
+	case "create_issue", "close_issue", "reopen_issue":
+		return "IssuesEvent"
+	case "create_pull_request", "merge_pull_request", "close_pull_request", "reopen_pull_request", "auto_merge_pull_request", "pull_request_ready_for_review":
+		return "PullRequestEvent"
+	case "approve_pull_request", "reject_pull_request", "pull_review_dismissed":
+		return "PullRequestReviewEvent"
+	case "comment_issue", "comment_pull":
+		return "IssueCommentEvent"
+	case "star_repo", "watch_repo":
+		return "WatchEvent"
+	case "publish_release":
+		return "ReleaseEvent"
+	default:
+		return a.OpType
+	}
+}
@@ -0,0 +1,57 @@
+package edb_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure the Gitea source converts activities and stops at the checkpoint.
+func TestGiteaSource_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/users/bob/activities/feeds" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		} else if auth := r.Header.Get("Authorization"); auth != "token XXX" {
+			t.Errorf("unexpected authorization: %s", auth)
+		} else if page := r.URL.Query().Get("page"); page != "1" {
+			t.Errorf("unexpected page: %s", page)
+		}
+		w.Write([]byte(`[
+			{"id":8,"op_type":"merge_pull_request","act_user":{"login":"bob"},"repo":{"full_name":"acme/widgets"},"created":"2000-01-03T00:00:00Z"},
+			{"id":7,"op_type":"commit_repo","act_user":{"login":"bob"},"repo":{"full_name":"acme/widgets"},"created":"2000-01-02T00:00:00Z","ref_name":"refs/heads/main"},
+			{"id":6,"op_type":"rename_repo","act_user":{"login":"bob"},"repo":{"full_name":"acme/widgets"},"created":"2000-01-01T12:00:00Z"},
+			{"id":5,"op_type":"create_issue","act_user":{"login":"bob"},"repo":{"full_name":"acme/widgets"},"created":"2000-01-01T00:00:00Z"}
+		]`))
+	}))
+	defer srv.Close()
+
+	s := edb.NewGiteaSource(srv.URL, "XXX", "bob")
+	s.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Fetch everything newer than activity 5.
+	events, checkpoint, err := s.Fetch(make(chan struct{}), "5")
+	if err != nil {
+		t.Fatal(err)
+	} else if checkpoint != "8" {
+		t.Fatalf("unexpected checkpoint: %s", checkpoint)
+	} else if len(events) != 3 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"PullRequestEvent", "PushEvent", "rename_repo"}) {
+		t.Fatalf("unexpected types: %v", types)
+	} else if e := events[1]; e.Actor != "bob" || e.Username != "bob" || e.Repository != "acme/widgets" || !e.Timestamp.Equal(MustParseTime("2000-01-02T00:00:00Z")) {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if len(e.Payload) == 0 {
+		t.Fatal("expected payload")
+	}
+}
@@ -1,26 +1,45 @@
 package edb
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/github"
 )
 
-// GitHubFetcher periodically fetches new events for a user.
-type GitHubFetcher struct {
+const (
+	// GitHubEventsPerPage is the number of events requested per page.
+	GitHubEventsPerPage = 100
+
+	// GitHubMaxEventPages is the maximum number of pages requested per fetch.
+	// At 100 events per page this covers GitHub's 300 event limit.
+	GitHubMaxEventPages = 3
+)
+
+// GitHubSource fetches new events performed by a GitHub user.
+// Its checkpoint is the ETag of the most recent event list.
+type GitHubSource struct {
 	Client   *github.Client
 	DB       *DB
 	Username string
 
+	// Shared scheduler used to stay within the API rate limit. Optional.
+	Scheduler *GitHubScheduler
+
+	// Poll interval most recently requested by the server.
+	pollInterval time.Duration
+
 	Logger *log.Logger
 }
 
-// NewGitHubFetcher returns a new GitHubFetcher for a user.
-func NewGitHubFetcher(client *github.Client, db *DB, username string) *GitHubFetcher {
-	return &GitHubFetcher{
+// NewGitHubSource returns a new GitHubSource for a user.
+func NewGitHubSource(client *github.Client, db *DB, username string) *GitHubSource {
+	return &GitHubSource{
 		Client:   client,
 		DB:       db,
 		Username: username,
@@ -29,56 +48,247 @@ func NewGitHubFetcher(client *github.Client, db *DB, username string) *GitHubFet
 	}
 }
 
-func (f *GitHubFetcher) Run(closing chan struct{}) {
-	start := make(chan struct{}, 1)
-	start <- struct{}{}
-
-	ticker := time.NewTicker(60 * time.Second)
-	for {
-		// Wait for next tick or close signal.
-		select {
-		case <-closing:
-			return
-		case <-start:
-		case <-ticker.C:
+// PollInterval returns the poll interval requested by GitHub, if any.
+func (s *GitHubSource) PollInterval() time.Duration {
+	return s.pollInterval
+}
+
+// Fetch retrieves new events for the user. Pages are followed until an event
+// already in the database is found or GitHub stops returning pages.
+//
+// The first page is requested conditionally using the etag checkpoint. If it
+// has not been modified then no events are returned.
+func (s *GitHubSource) Fetch(closing chan struct{}, etag string) ([]Event, string, error) {
+	var events []Event
+	var overlap bool
+	var newETag string
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("users/%s/events/public?per_page=%d&page=%d", s.Username, GitHubEventsPerPage, page)
+		req, err := s.Client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		if page == 1 && etag != "" {
+			req.Header.Set("If-None-Match", etag)
 		}
 
-		f.Logger.Printf("fetching %s", f.Username)
+		// Wait for our turn within the rate limit.
+		if s.Scheduler != nil && !s.Scheduler.Wait(closing) {
+			return nil, "", errClosing
+		}
 
-		// Fetch events from github.
-		a, resp, err := f.Client.Activity.ListEventsPerformedByUser(f.Username, true, nil)
+		// Execute request. A 304 is not an error so check it first.
+		var a []github.Event
+		resp, err := s.Client.Do(req, &a)
+		if resp != nil && s.Scheduler != nil {
+			s.Scheduler.Update(resp)
+		}
+		if resp != nil && page == 1 {
+			s.setPollInterval(resp.Header.Get("X-Poll-Interval"))
+			if resp.StatusCode == http.StatusNotModified {
+				s.Logger.Printf("no new events for %s", s.Username)
+				return nil, etag, nil
+			}
+			newETag = resp.Header.Get("ETag")
+		}
 		if err != nil {
-			f.Logger.Printf("list events: %s", err)
-			continue
+			return nil, "", fmt.Errorf("list events: %s", err)
 		} else if resp.StatusCode != http.StatusOK {
-			f.Logger.Printf("list events: status=%d", resp.StatusCode)
-			continue
+			return nil, "", fmt.Errorf("list events: status=%d", resp.StatusCode)
 		}
-		f.Logger.Printf("received %d events (remaining=%d)", len(a), resp.Rate.Remaining)
+		s.Logger.Printf("received %d events (page=%d, remaining=%d)", len(a), page, resp.Rate.Remaining)
 
-		// Convert events.
-		var events []Event
+		// Convert events until we reach one we already have.
 		for _, ghe := range a {
-			e := Event{
-				ID:        *ghe.ID,
-				Type:      *ghe.Type,
-				Timestamp: *ghe.CreatedAt,
-				Username:  f.Username,
-			}
-			if ghe.Actor != nil {
-				e.Actor = *ghe.Actor.Login
-			}
-			if ghe.Repo != nil {
-				e.Repository = *ghe.Repo.Name
+			e := s.convert(ghe)
+			if prev, err := s.DB.Event(e.Actor, e.ID); err != nil {
+				return nil, "", err
+			} else if prev != nil {
+				overlap = true
+				break
 			}
-
 			events = append(events, e)
 		}
 
-		// Save events to database.
-		if err := f.DB.SaveEvents(events); err != nil {
-			f.Logger.Printf("save events: %s", err)
-			continue
+		// Stop if we've caught up or there are no more pages.
+		if overlap || resp.NextPage == 0 || page >= GitHubMaxEventPages {
+			break
+		}
+	}
+
+	// If we never reached a stored event but have seen this user before then
+	// some events may have been missed between fetches.
+	if !overlap && len(events) > 0 {
+		if a, err := s.DB.Query(&Query{Actors: []string{s.Username}, Limit: 1}); err != nil {
+			return nil, "", err
+		} else if len(a) > 0 {
+			s.Logger.Printf("possible gap in events for %s: no previously stored event found in %d new events", s.Username, len(events))
+		}
+	}
+
+	return events, newETag, nil
+}
+
+// setPollInterval updates the poll interval from an X-Poll-Interval header.
+func (s *GitHubSource) setPollInterval(v string) {
+	if v == "" {
+		return
+	} else if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		s.pollInterval = time.Duration(n) * time.Second
+	}
+}
+
+// convert returns a generic event from a GitHub event.
+func (s *GitHubSource) convert(ghe github.Event) Event {
+	e := Event{
+		ID:        *ghe.ID,
+		Type:      *ghe.Type,
+		Timestamp: *ghe.CreatedAt,
+		Username:  s.Username,
+	}
+	if ghe.Actor != nil {
+		e.Actor = *ghe.Actor.Login
+	}
+	if ghe.Repo != nil {
+		e.Repository = *ghe.Repo.Name
+	}
+	if ghe.Org != nil {
+		e.Org = *ghe.Org.Login
+	}
+	if ghe.RawPayload != nil {
+		e.Payload = *ghe.RawPayload
+	}
+	return e
+}
+
+// DefaultGitHubInterval is the default time between fetches.
+const DefaultGitHubInterval = DefaultFetchInterval
+
+// GitHubFetcher periodically fetches new events for a GitHub user.
+// It runs a Fetcher around a GitHubSource.
+type GitHubFetcher struct {
+	Client   *github.Client
+	DB       *DB
+	Username string
+
+	// Minimum time between fetches. The server's X-Poll-Interval is used
+	// instead when it is longer.
+	Interval time.Duration
+
+	// Delay before the first fetch. Used to spread out multiple fetchers.
+	Offset time.Duration
+
+	// Shared scheduler used to stay within the API rate limit. Optional.
+	Scheduler *GitHubScheduler
+
+	Logger *log.Logger
+}
+
+// NewGitHubFetcher returns a new GitHubFetcher for a user.
+func NewGitHubFetcher(client *github.Client, db *DB, username string) *GitHubFetcher {
+	return &GitHubFetcher{
+		Client:   client,
+		DB:       db,
+		Username: username,
+		Interval: DefaultGitHubInterval,
+
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Run fetches the user's events on an interval until closing is closed.
+func (f *GitHubFetcher) Run(closing chan struct{}) {
+	src := NewGitHubSource(f.Client, f.DB, f.Username)
+	src.Scheduler = f.Scheduler
+	src.Logger = f.Logger
+
+	fetcher := NewFetcher("github/"+f.Username, src, f.DB)
+	fetcher.Interval = f.Interval
+	fetcher.Offset = f.Offset
+	fetcher.Logger = f.Logger
+	fetcher.Run(closing)
+}
+
+// GitHubScheduler coordinates requests from fetchers sharing a GitHub client.
+// Requests are spaced so the remaining quota lasts until the rate limit
+// resets, and all requests are held back when the limit has been exhausted.
+type GitHubScheduler struct {
+	mu   sync.Mutex
+	next time.Time   // earliest time of the next request
+	rate github.Rate // most recently reported rate limit
+}
+
+// NewGitHubScheduler returns a new instance of GitHubScheduler.
+func NewGitHubScheduler() *GitHubScheduler {
+	return &GitHubScheduler{}
+}
+
+// Wait blocks until the next request may be sent and reserves it.
+// Returns false if closing is closed first.
+func (s *GitHubScheduler) Wait(closing chan struct{}) bool {
+	s.mu.Lock()
+	now := time.Now()
+	t := s.next
+	if t.Before(now) {
+		t = now
+	}
+	s.next = t.Add(s.spacing(t))
+	s.mu.Unlock()
+
+	// Wait until our reserved time or close signal.
+	timer := time.NewTimer(t.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-closing:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// spacing returns the time between requests that spreads the remaining
+// quota evenly over the rest of the rate limit window. Must hold lock.
+func (s *GitHubScheduler) spacing(now time.Time) time.Duration {
+	if s.rate.Limit == 0 || !s.rate.Reset.After(now) {
+		return 0
+	} else if s.rate.Remaining <= 0 {
+		return s.rate.Reset.Sub(now)
+	}
+	return s.rate.Reset.Sub(now) / time.Duration(s.rate.Remaining)
+}
+
+// Update records the rate limit reported by a response. If the response is
+// a rate limit error then all requests are delayed until the limit resets.
+func (s *GitHubScheduler) Update(resp *github.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resp.Rate.Limit > 0 {
+		s.rate = resp.Rate
+	}
+
+	// Back off until reset once the quota is exhausted.
+	if resp.Rate.Limit > 0 && resp.Rate.Remaining == 0 {
+		s.backoff(resp.Rate.Reset.Time)
+	}
+
+	// Secondary rate limits report a Retry-After header instead.
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if n, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			s.backoff(time.Now().Add(time.Duration(n) * time.Second))
 		}
 	}
 }
+
+// Backoff prevents any requests from being sent until t.
+func (s *GitHubScheduler) Backoff(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backoff(t)
+}
+
+func (s *GitHubScheduler) backoff(t time.Time) {
+	if t.After(s.next) {
+		s.next = t
+	}
+}
@@ -0,0 +1,298 @@
+package edb_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/edb"
+	"github.com/google/go-github/github"
+)
+
+// Ensure the GitHub source follows pages until it reaches a stored event.
+func TestGitHubSource_Fetch(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Store the oldest event so fetching stops once it is reached.
+	if err := db.SaveEvents([]edb.Event{{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Serve two pages of events.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/bob/events/public" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("X-Poll-Interval", "120")
+			w.Header().Set("Link", `<http://`+r.Host+`/users/bob/events/public?page=2>; rel="next"`)
+			w.Write([]byte(`[{"id":"3","type":"PushEvent","created_at":"2000-01-03T00:00:00Z","actor":{"login":"bob"},"repo":{"name":"bob/foo"},"payload":{"size":1}}]`))
+		case "2":
+			w.Write([]byte(`[{"id":"2","type":"IssuesEvent","created_at":"2000-01-02T00:00:00Z","actor":{"login":"bob"},"repo":{"name":"bob/foo"}},` +
+				`{"id":"1","type":"PushEvent","created_at":"2000-01-01T00:00:00Z","actor":{"login":"bob"}}]`))
+		default:
+			t.Errorf("unexpected page: %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer srv.Close()
+
+	s := MustNewTestGitHubSource(srv.URL, db, "bob")
+	events, etag, err := s.Fetch(make(chan struct{}), "")
+	if err != nil {
+		t.Fatal(err)
+	} else if etag != `"abc"` {
+		t.Fatalf("unexpected etag: %s", etag)
+	} else if ids := EventIDs(events); !reflect.DeepEqual(ids, []string{"3", "2"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	} else if events[0].Repository != "bob/foo" || events[0].Username != "bob" {
+		t.Fatalf("unexpected event: %#v", events[0])
+	} else if string(events[0].Payload) != `{"size":1}` {
+		t.Fatalf("unexpected payload: %s", events[0].Payload)
+	} else if s.PollInterval() != 120*time.Second {
+		t.Fatalf("unexpected poll interval: %s", s.PollInterval())
+	}
+}
+
+// Ensure the GitHub source doesn't request more pages once it reaches a stored event.
+func TestGitHubSource_Fetch_Overlap(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SaveEvents([]edb.Event{{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first page contains the stored event but still links to a second page.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if page := r.URL.Query().Get("page"); page != "1" {
+			t.Errorf("unexpected page: %s", page)
+		}
+		w.Header().Set("Link", `<http://`+r.Host+`/users/bob/events/public?page=2>; rel="next"`)
+		w.Write([]byte(`[{"id":"2","type":"PushEvent","created_at":"2000-01-02T00:00:00Z","actor":{"login":"bob"}},` +
+			`{"id":"1","type":"PushEvent","created_at":"2000-01-01T00:00:00Z","actor":{"login":"bob"}}]`))
+	}))
+	defer srv.Close()
+
+	if events, _, err := MustNewTestGitHubSource(srv.URL, db, "bob").Fetch(make(chan struct{}), ""); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(events); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure the GitHub source stops following pages at GitHub's page limit.
+func TestGitHubSource_Fetch_MaxPages(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Every page links to another page of unseen events.
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if n := r.URL.Query().Get("per_page"); n != strconv.Itoa(edb.GitHubEventsPerPage) {
+			t.Errorf("unexpected per_page: %s", n)
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/users/bob/events/public?page=%d>; rel="next"`, r.Host, page+1))
+		fmt.Fprintf(w, `[{"id":"%d","type":"PushEvent","created_at":"2000-01-01T00:00:00Z","actor":{"login":"bob"}}]`, page)
+	}))
+	defer srv.Close()
+
+	if events, _, err := MustNewTestGitHubSource(srv.URL, db, "bob").Fetch(make(chan struct{}), ""); err != nil {
+		t.Fatal(err)
+	} else if len(events) != edb.GitHubMaxEventPages {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if pages != edb.GitHubMaxEventPages {
+		t.Fatalf("unexpected page count: %d", pages)
+	}
+}
+
+// Ensure the GitHub source returns no events when the list is not modified.
+func TestGitHubSource_Fetch_NotModified(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("If-None-Match"); v != `"abc"` {
+			t.Errorf("unexpected If-None-Match: %s", v)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	if events, etag, err := MustNewTestGitHubSource(srv.URL, db, "bob").Fetch(make(chan struct{}), `"abc"`); err != nil {
+		t.Fatal(err)
+	} else if len(events) != 0 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if etag != `"abc"` {
+		t.Fatalf("unexpected etag: %s", etag)
+	}
+}
+
+// Ensure the GitHub source sends the ETag from its previous fetch and treats
+// a 304 as no new events while still honoring the poll interval.
+func TestGitHubSource_Fetch_ETag(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("If-None-Match") {
+		case "":
+			w.Header().Set("ETag", `"abc"`)
+			w.Write([]byte(`[{"id":"1","type":"PushEvent","created_at":"2000-01-01T00:00:00Z","actor":{"login":"bob"}}]`))
+		case `"abc"`:
+			w.Header().Set("X-Poll-Interval", "90")
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			t.Errorf("unexpected If-None-Match: %s", r.Header.Get("If-None-Match"))
+		}
+	}))
+	defer srv.Close()
+	s := MustNewTestGitHubSource(srv.URL, db, "bob")
+
+	// The first fetch has no checkpoint so the full list is returned.
+	events, etag, err := s.Fetch(make(chan struct{}), "")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(events) != 1 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if etag != `"abc"` {
+		t.Fatalf("unexpected etag: %s", etag)
+	}
+
+	// The next fetch is conditional and the list has not changed.
+	if events, etag, err = s.Fetch(make(chan struct{}), etag); err != nil {
+		t.Fatal(err)
+	} else if len(events) != 0 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if etag != `"abc"` {
+		t.Fatalf("unexpected etag: %s", etag)
+	} else if s.PollInterval() != 90*time.Second {
+		t.Fatalf("unexpected poll interval: %s", s.PollInterval())
+	}
+}
+
+// Ensure the GitHub fetcher saves the user's events and persists the ETag.
+func TestGitHubFetcher_Run(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte(`[{"id":"1","type":"PushEvent","created_at":"2000-01-01T00:00:00Z","actor":{"login":"bob"}}]`))
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	f := edb.NewGitHubFetcher(client, db, "bob")
+	f.Interval = time.Hour
+	f.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Run fetcher until the checkpoint is saved after the first fetch.
+	closing, done := make(chan struct{}), make(chan struct{})
+	go func() { f.Run(closing); close(done) }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if v, err := db.Checkpoint("github/bob"); err != nil {
+			t.Fatal(err)
+		} else if v == `"abc"` {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for checkpoint")
+		}
+	}
+	close(closing)
+	<-done
+
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure the scheduler holds requests back until a backoff expires.
+func TestGitHubScheduler_Backoff(t *testing.T) {
+	s := edb.NewGitHubScheduler()
+	s.Backoff(time.Now().Add(50 * time.Millisecond))
+
+	start := time.Now()
+	if !s.Wait(make(chan struct{})) {
+		t.Fatal("expected wait to succeed")
+	} else if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("wait returned too early: %s", d)
+	}
+}
+
+// Ensure the scheduler waits for the rate limit reset once exhausted.
+func TestGitHubScheduler_Update_Exhausted(t *testing.T) {
+	s := edb.NewGitHubScheduler()
+	s.Update(&github.Response{
+		Response: &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}},
+		Rate:     github.Rate{Limit: 5000, Remaining: 0, Reset: github.Timestamp{Time: time.Now().Add(time.Hour)}},
+	})
+
+	// Waiting should block until closed.
+	closing := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(closing) })
+	if s.Wait(closing) {
+		t.Fatal("expected wait to be interrupted")
+	}
+}
+
+// Ensure the scheduler does not delay requests when quota is plentiful.
+func TestGitHubScheduler_Wait(t *testing.T) {
+	s := edb.NewGitHubScheduler()
+	s.Update(&github.Response{
+		Response: &http.Response{StatusCode: http.StatusOK, Header: http.Header{}},
+		Rate:     github.Rate{Limit: 5000, Remaining: 4999, Reset: github.Timestamp{Time: time.Now().Add(time.Second)}},
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !s.Wait(make(chan struct{})) {
+			t.Fatal("expected wait to succeed")
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("unexpected delay: %s", d)
+	}
+}
+
+// MustNewTestGitHubSource returns a GitHub source for a user against a test server.
+func MustNewTestGitHubSource(baseURL string, db *edb.DB, username string) *edb.GitHubSource {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(baseURL + "/")
+
+	s := edb.NewGitHubSource(client, db, username)
+	s.Logger = log.New(ioutil.Discard, "", 0)
+	return s
+}
@@ -0,0 +1,246 @@
+package edb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// GitLabEventsPerPage is the number of events requested per page.
+	GitLabEventsPerPage = 100
+
+	// GitLabMaxEventPages is the maximum number of pages read per fetch.
+	GitLabMaxEventPages = 10
+)
+
+// GitLabSource fetches new events performed by a GitLab user.
+// Its checkpoint is the id of the most recent event seen.
+type GitLabSource struct {
+	// Base URL of the GitLab instance, e.g. "https://gitlab.com".
+	URL string
+
+	// Personal access token used to authenticate requests.
+	Token string
+
+	// Username or numeric id of the user to fetch events for.
+	Username string
+
+	// HTTP client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Cache of project paths by project id.
+	projects map[int]string
+
+	Logger *log.Logger
+}
+
+// NewGitLabSource returns a new GitLabSource for a user on a GitLab instance.
+func NewGitLabSource(u, token, username string) *GitLabSource {
+	return &GitLabSource{
+		URL:      u,
+		Token:    token,
+		Username: username,
+		Client:   http.DefaultClient,
+
+		projects: make(map[int]string),
+
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Fetch retrieves events newer than the checkpoint event id. Pages are read
+// newest first until an already seen event is reached.
+func (s *GitLabSource) Fetch(closing chan struct{}, checkpoint string) ([]Event, string, error) {
+	var last int
+	if checkpoint != "" {
+		n, err := strconv.Atoi(checkpoint)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid checkpoint: %s", checkpoint)
+		}
+		last = n
+	}
+
+	var events []Event
+	var overlap bool
+	next := last
+	for page := 1; page <= GitLabMaxEventPages; page++ {
+		// Stop if we're shutting down.
+		select {
+		case <-closing:
+			return nil, "", errClosing
+		default:
+		}
+
+		// Retrieve the next page of events.
+		var a []*gitlabEvent
+		resp, err := s.get(fmt.Sprintf("/api/v4/users/%s/events?sort=desc&per_page=%d&page=%d", url.PathEscape(s.Username), GitLabEventsPerPage, page), &a)
+		if err != nil {
+			return nil, "", fmt.Errorf("list events: %s", err)
+		}
+		s.Logger.Printf("received %d gitlab events (page=%d)", len(a), page)
+
+		// Convert events until we reach one we already have.
+		for _, gle := range a {
+			if gle.ID <= last {
+				overlap = true
+				break
+			} else if gle.ID > next {
+				next = gle.ID
+			}
+
+			events = append(events, s.convert(gle))
+		}
+
+		// Stop if we've caught up or there are no more pages.
+		if overlap || resp.Header.Get("X-Next-Page") == "" {
+			break
+		}
+	}
+
+	// Warn if we've fetched before but never reached a previously seen event.
+	if !overlap && last > 0 && len(events) > 0 {
+		s.Logger.Printf("possible gap in gitlab events for %s: no previously seen event found in %d new events", s.Username, len(events))
+	}
+
+	if next == 0 {
+		return events, checkpoint, nil
+	}
+	return events, strconv.Itoa(next), nil
+}
+
+// convert returns a generic event from a GitLab event. The repository is left
+// blank if the project cannot be looked up, such as after it is deleted, so
+// one missing project doesn't stop the rest of the user's events.
+func (s *GitLabSource) convert(gle *gitlabEvent) Event {
+	e := Event{
+		ID:        fmt.Sprintf("gitlab:%s:%d", s.host(), gle.ID),
+		Type:      gle.eventType(),
+		Timestamp: gle.CreatedAt,
+		Username:  s.Username,
+		Actor:     gle.AuthorUsername,
+		Payload:   gle.raw,
+	}
+
+	// Look up the repository path for the project.
+	if gle.ProjectID != 0 {
+		path, err := s.projectPath(gle.ProjectID)
+		if err != nil {
+			s.Logger.Printf("gitlab project %d: %s", gle.ProjectID, err)
+		}
+		e.Repository = path
+	}
+
+	return e
+}
+
+// projectPath returns the full path of a project, e.g. "group/project".
+func (s *GitLabSource) projectPath(id int) (string, error) {
+	if path, ok := s.projects[id]; ok {
+		return path, nil
+	}
+
+	var p struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	}
+	if _, err := s.get(fmt.Sprintf("/api/v4/projects/%d", id), &p); err != nil {
+		return "", fmt.Errorf("get project: %s", err)
+	}
+	s.projects[id] = p.PathWithNamespace
+
+	return p.PathWithNamespace, nil
+}
+
+// get executes a GET request against the API and decodes the JSON response into v.
+func (s *GitLabSource) get(path string, v interface{}) (*http.Response, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(s.URL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status=%d", resp.StatusCode)
+	} else if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// host returns the host name of the GitLab instance.
+func (s *GitLabSource) host() string {
+	if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return s.URL
+}
+
+// gitlabEvent represents an event returned from the GitLab events API.
+type gitlabEvent struct {
+	ID             int       `json:"id"`
+	ProjectID      int       `json:"project_id"`
+	ActionName     string    `json:"action_name"`
+	TargetType     string    `json:"target_type"`
+	AuthorUsername string    `json:"author_username"`
+	CreatedAt      time.Time `json:"created_at"`
+	PushData       *struct{} `json:"push_data"`
+
+	// Original JSON of the event.
+	raw json.RawMessage
+}
+
+// UnmarshalJSON decodes the event and retains the original JSON.
+func (e *gitlabEvent) UnmarshalJSON(b []byte) error {
+	type alias gitlabEvent
+	if err := json.Unmarshal(b, (*alias)(e)); err != nil {
+		return err
+	}
+	e.raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// eventType maps the GitLab action & target onto GitHub-style event types so
+// events from both services can be charted together.
+func (e *gitlabEvent) eventType() string {
+	switch e.TargetType {
+	case "MergeRequest":
+		return "PullRequestEvent"
+	case "Issue":
+		return "IssuesEvent"
+	case "Note", "DiffNote", "DiscussionNote":
+		return "IssueCommentEvent"
+	case "":
+	default:
+		return e.TargetType + "Event"
+	}
+
+	switch e.ActionName {
+	case "pushed to", "pushed new":
+		return "PushEvent"
+	case "deleted":
+		return "DeleteEvent"
+	case "created":
+		return "CreateEvent"
+	case "joined", "left":
+		return "MemberEvent"
+	default:
+		if e.PushData != nil {
+			return "PushEvent"
+		}
+		return "GitLabEvent"
+	}
+}
@@ -0,0 +1,103 @@
+package edb_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure the GitLab source converts events and stops at the checkpoint.
+func TestGitLabSource_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tok := r.Header.Get("PRIVATE-TOKEN"); tok != "XXX" {
+			t.Errorf("unexpected token: %s", tok)
+		}
+
+		switch r.URL.Path {
+		case "/api/v4/users/bob/events":
+			switch r.URL.Query().Get("page") {
+			case "1":
+				w.Header().Set("X-Next-Page", "2")
+				w.Write([]byte(`[
+					{"id":13,"project_id":5,"action_name":"opened","target_type":"MergeRequest","author_username":"bob","created_at":"2000-01-03T00:00:00Z"},
+					{"id":12,"project_id":5,"action_name":"commented on","target_type":"Note","author_username":"bob","created_at":"2000-01-02T12:00:00Z"}
+				]`))
+			case "2":
+				w.Write([]byte(`[
+					{"id":11,"project_id":5,"action_name":"pushed to","author_username":"bob","created_at":"2000-01-02T00:00:00Z","push_data":{"commit_count":2}},
+					{"id":10,"project_id":5,"action_name":"opened","target_type":"Issue","author_username":"bob","created_at":"2000-01-01T00:00:00Z"}
+				]`))
+			default:
+				t.Errorf("unexpected page: %s", r.URL.Query().Get("page"))
+			}
+		case "/api/v4/projects/5":
+			w.Write([]byte(`{"id":5,"path_with_namespace":"acme/widgets"}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := edb.NewGitLabSource(srv.URL, "XXX", "bob")
+	s.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Fetch everything newer than event 10.
+	events, checkpoint, err := s.Fetch(make(chan struct{}), "10")
+	if err != nil {
+		t.Fatal(err)
+	} else if checkpoint != "13" {
+		t.Fatalf("unexpected checkpoint: %s", checkpoint)
+	} else if len(events) != 3 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"PullRequestEvent", "IssueCommentEvent", "PushEvent"}) {
+		t.Fatalf("unexpected types: %v", types)
+	} else if e := events[2]; e.Actor != "bob" || e.Username != "bob" || e.Repository != "acme/widgets" || !e.Timestamp.Equal(MustParseTime("2000-01-02T00:00:00Z")) {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if len(e.Payload) == 0 {
+		t.Fatal("expected payload")
+	}
+}
+
+// Ensure events are still returned when their project cannot be found.
+func TestGitLabSource_Fetch_ProjectNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/users/bob/events":
+			w.Write([]byte(`[
+				{"id":12,"project_id":6,"action_name":"pushed to","author_username":"bob","created_at":"2000-01-02T00:00:00Z"},
+				{"id":11,"project_id":5,"action_name":"pushed to","author_username":"bob","created_at":"2000-01-01T00:00:00Z"}
+			]`))
+		case "/api/v4/projects/5":
+			w.Write([]byte(`{"id":5,"path_with_namespace":"acme/widgets"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := edb.NewGitLabSource(srv.URL, "XXX", "bob")
+	s.Logger = log.New(ioutil.Discard, "", 0)
+
+	events, checkpoint, err := s.Fetch(make(chan struct{}), "")
+	if err != nil {
+		t.Fatal(err)
+	} else if checkpoint != "12" {
+		t.Fatalf("unexpected checkpoint: %s", checkpoint)
+	} else if len(events) != 2 {
+		t.Fatalf("unexpected event count: %d", len(events))
+	} else if events[0].Repository != "" || events[1].Repository != "acme/widgets" {
+		t.Fatalf("unexpected repositories: %q, %q", events[0].Repository, events[1].Repository)
+	}
+}
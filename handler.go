@@ -1,12 +1,20 @@
 package edb
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"mime"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/benbjohnson/edb/assets"
 )
@@ -19,28 +27,180 @@ type Handler struct {
 
 	// Enables the use of the local file system for assets, when true.
 	LocalMode bool
+
+	// Secret used to verify GitHub webhook deliveries. Webhooks are
+	// disabled when blank.
+	WebhookSecret string
+
+	// Maximum size of a request to POST /events, in bytes.
+	// Defaults to DefaultMaxIngestSize.
+	MaxIngestSize int64
+
+	// Requires a bearer token with the appropriate scope, when true.
+	// Webhooks are verified by their signature instead.
+	RequireAuth bool
+
+	// Serves the dashboard and its assets without a token, when true.
+	PublicAssets bool
+
+	// Policy for cross-origin requests. Browsers on other origins are
+	// denied access when nil.
+	CORS *CORS
+
+	// Time between keepalive messages on long-lived connections.
+	// Defaults to DefaultKeepAliveInterval.
+	KeepAliveInterval time.Duration
 }
 
+// DefaultMaxIngestSize is the default maximum size of an ingest request.
+const DefaultMaxIngestSize = 10 << 20
+
+// DefaultKeepAliveInterval is the default time between keepalive messages
+// sent on event streams and WebSocket connections.
+const DefaultKeepAliveInterval = 30 * time.Second
+
+// StreamFlushSize is the number of events written between flushes when
+// streaming events in an export format.
+const StreamFlushSize = 1000
+
+// MaxWebhookSize is the maximum size of a webhook delivery, in bytes.
+const MaxWebhookSize = 25 << 20
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Apply the cross-origin policy. Preflight requests carry no token so
+	// they are answered before authentication.
+	if h.CORS != nil && h.CORS.handle(w, r) {
+		return
+	}
+
+	// Check the request's token, if required.
+	if h.RequireAuth {
+		if scope := h.requiredScope(r); scope != "" && !h.authorize(w, r, scope) {
+			return
+		}
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/assets/") {
 		h.serveAsset(w, r, strings.TrimPrefix(r.URL.Path, "/assets/"))
 		return
 	}
 
+	// Serve events for a single repository: /repos/:owner/:name/events.json
+	if strings.HasPrefix(r.URL.Path, "/repos/") && strings.HasSuffix(r.URL.Path, "/events.json") {
+		repository := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/repos/"), "/events.json")
+		if r.Method == "GET" {
+			h.serveEventsBy(w, r, func(q *Query) { q.Repositories = []string{repository} })
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	// Serve events of a single type: /types/:type/events.json
+	if strings.HasPrefix(r.URL.Path, "/types/") && strings.HasSuffix(r.URL.Path, "/events.json") {
+		typ := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/types/"), "/events.json")
+		if r.Method == "GET" {
+			h.serveEventsBy(w, r, func(q *Query) { q.Types = []string{typ} })
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
 	switch r.URL.Path {
 	case "/":
 		h.serveAsset(w, r, "index.html")
-	case "/events.json":
+	case "/events.json", "/events.ndjson", "/events.csv":
 		if r.Method == "GET" {
 			h.serveEvents(w, r)
 		} else {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
+	case "/stats":
+		if r.Method == "GET" {
+			h.serveStats(w, r)
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/events/stream":
+		if r.Method == "GET" {
+			h.serveEventStream(w, r)
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/events/ws":
+		if r.Method == "GET" {
+			h.serveWebSocket(w, r)
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/events":
+		if r.Method == "POST" {
+			h.serveIngest(w, r)
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/webhooks/github":
+		if r.Method == "POST" {
+			h.serveGitHubWebhook(w, r)
+		} else {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// requiredScope returns the token scope needed for a request.
+// Returns a blank string if the request does not require a token.
+func (h *Handler) requiredScope(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/webhooks/github":
+		return ""
+	case r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/assets/"):
+		if h.PublicAssets {
+			return ""
+		}
+		return ScopeRead
+	case r.Method == "POST":
+		return ScopeIngest
+	default:
+		return ScopeRead
+	}
+}
+
+// authorize checks that the request's bearer token grants scope.
+// Writes an error and returns false if it does not.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, scope string) bool {
+	var secret string
+	if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+		secret = strings.TrimPrefix(v, "Bearer ")
+	} else if r.URL.Path == "/events/stream" || r.URL.Path == "/events/ws" {
+		// Browsers cannot set headers on an EventSource or WebSocket so
+		// allow a parameter.
+		secret = r.URL.Query().Get("access_token")
+	}
+	if secret == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="edb"`)
+		http.Error(w, "token required", http.StatusUnauthorized)
+		return false
+	}
+
+	t, err := h.DB.Authenticate(secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	} else if t == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="edb", error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	} else if !t.HasScope(scope) {
+		http.Error(w, fmt.Sprintf("token requires %s scope", scope), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // Serves a file from the local file system or embedded in the binary.
 func (h *Handler) serveAsset(w http.ResponseWriter, r *http.Request, filename string) {
 	// Serve from local file system in local mode.
@@ -65,15 +225,71 @@ func (h *Handler) serveAsset(w http.ResponseWriter, r *http.Request, filename st
 	}
 }
 
-// serveEvents writes all events in the database to the handler.
+// serveEvents writes events in the database to the handler. Events can be
+// filtered by "actor", "repo", "type", "username", "since" and "until".
+// Event payloads are omitted when "payload" is false.
+// Results can be paged by passing "limit" and the "after" cursor from the
+// previous page. A Link header with the next page is included when more
+// results may exist.
+//
+// Events are streamed as newline-delimited JSON, without a Link header, for
+// "/events.ndjson" or when the Accept header is "application/x-ndjson".
+// They are streamed as CSV for "/events.csv", where each "column" parameter
+// adds a column for a payload field and "payload" false omits the raw payload.
 func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
-	// Retrieve list of events.
-	a, err := h.DB.Events()
+	h.serveEventsBy(w, r, func(*Query) {})
+}
+
+// serveEventsBy writes events to the handler after fn restricts the query.
+func (h *Handler) serveEventsBy(w http.ResponseWriter, r *http.Request, fn func(q *Query)) {
+	// Parse query from the URL.
+	q, err := parseQuery(r.URL.Query())
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fn(q)
+
+	// Determine whether to include event payloads.
+	payload := true
+	if s := r.URL.Query().Get("payload"); s != "" {
+		if payload, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid payload: %s", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Stream events, if requested, instead of building the whole response.
+	if strings.HasSuffix(r.URL.Path, ".csv") {
+		enc := NewCSVEncoder(w)
+		enc.PayloadColumns = r.URL.Query()["column"]
+		enc.OmitPayload = !payload
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8; header=present")
+		h.writeEvents(w, q, enc, false)
+		return
+	} else if strings.HasSuffix(r.URL.Path, ".ndjson") || acceptsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		h.writeEvents(w, q, NewNDJSONEncoder(w), !payload)
+		return
+	}
+
+	// Retrieve list of events.
+	a, err := h.DB.Query(q)
+	if err == ErrInvalidCursor {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Strip payloads, if requested.
+	if !payload {
+		for i := range a {
+			a[i].Payload = nil
+		}
+	}
+
 	// Marshal events with pretty printing.
 	b, err := json.MarshalIndent(a, "", "  ")
 	if err != nil {
@@ -81,8 +297,14 @@ func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Allow any front end to access our data.
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// Link to the next page if this page is full.
+	if q.Limit > 0 && len(a) == q.Limit {
+		u := *r.URL
+		values := u.Query()
+		values.Set("after", a[len(a)-1].Cursor())
+		u.RawQuery = values.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+	}
 
 	// Write JSON out to response.
 	w.Header().Set("Content-Type", "application/json")
@@ -90,3 +312,378 @@ func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
 		log.Print(err)
 	}
 }
+
+// writeEvents encodes events matching q directly from the database,
+// flushing every StreamFlushSize events. Payloads are removed if strip is true.
+func (h *Handler) writeEvents(w http.ResponseWriter, q *Query, enc EventEncoder, strip bool) {
+	flusher, _ := w.(http.Flusher)
+
+	var n int
+	err := h.DB.ForEach(q, func(e *Event) error {
+		if strip {
+			e.Payload = nil
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+
+		if n++; flusher != nil && n%StreamFlushSize == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+
+	// Errors can only be reported if nothing has been written yet.
+	if err == ErrInvalidCursor {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if err != nil && n == 0 {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if err != nil {
+		log.Printf("write events: %s", err)
+	}
+}
+
+// acceptsNDJSON returns true if the client accepts newline-delimited JSON.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(v)); mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveStats writes event counts grouped by each "group" parameter ("actor",
+// "repo" or "type") and by "interval" ("hour", "day", "week" or "month") in
+// the "tz" time zone. Events are filtered the same as serveEvents.
+func (h *Handler) serveStats(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	q, err := parseQuery(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Parse grouping. Groups may be repeated or comma-separated.
+	a := &Aggregation{Query: *q, Interval: values.Get("interval")}
+	for _, v := range values["group"] {
+		a.GroupBy = append(a.GroupBy, strings.Split(v, ",")...)
+	}
+	if tz := values.Get("tz"); tz != "" {
+		if a.Location, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, fmt.Sprintf("invalid tz: %s", tz), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Count events.
+	groups, err := h.DB.Aggregate(a)
+	if err == ErrInvalidGroup || err == ErrInvalidInterval {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(b); err != nil {
+		log.Print(err)
+	}
+}
+
+// serveEventStream streams new events as server-sent events. Events can be
+// filtered by "actor", "repo", "type" and "username". Each message id is the
+// event's cursor. Events after the cursor in the Last-Event-ID header, or the
+// "after" parameter, are sent first so reconnecting clients can resume.
+func (h *Handler) serveEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Parse filters from the URL.
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		q.After = id
+	}
+
+	// Subscribe before reading missed events so none fall in between.
+	ch, cancel := h.DB.Subscribe(q)
+	defer cancel()
+
+	// Retrieve events missed since the last one the client received.
+	var missed []Event
+	if q.After != "" {
+		if missed, err = h.DB.Query(q); err == ErrInvalidCursor {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// Send missed events, remembering them in case they are also received
+	// from the subscription.
+	sent := make(map[string]bool)
+	for i := range missed {
+		if err := writeServerSentEvent(w, &missed[i]); err != nil {
+			return
+		}
+		sent[string(eventRef(&missed[i]))] = true
+	}
+	flusher.Flush()
+
+	// Send new events as they are saved until the client disconnects.
+	ticker := time.NewTicker(h.keepAliveInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+
+		case e, ok := <-ch:
+			// The subscription closes if we fall behind. The client
+			// reconnects and resumes from its last event.
+			if !ok {
+				return
+			} else if sent[string(eventRef(&e))] {
+				continue
+			} else if err := writeServerSentEvent(w, &e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// keepAliveInterval returns the time between keepalive messages.
+func (h *Handler) keepAliveInterval() time.Duration {
+	if h.KeepAliveInterval == 0 {
+		return DefaultKeepAliveInterval
+	}
+	return h.KeepAliveInterval
+}
+
+// writeServerSentEvent writes an event as a server-sent event message.
+func writeServerSentEvent(w io.Writer, e *Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", e.Cursor(), b)
+	return err
+}
+
+// serveGitHubWebhook verifies a GitHub webhook delivery and saves it as an event.
+func (h *Handler) serveGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.WebhookSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Read the body, which must be intact to verify the signature.
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxWebhookSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if len(body) > MaxWebhookSize {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Verify the delivery came from GitHub.
+	if err := VerifyGitHubSignature(h.WebhookSecret, r.Header.Get("X-Hub-Signature-256"), body); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Acknowledge pings sent when the webhook is created.
+	name := r.Header.Get("X-GitHub-Event")
+	if name == "ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Convert and save the event. Deliveries without an actor, such as those
+	// missing a sender, cannot be stored.
+	e, err := NewGitHubWebhookEvent(name, r.Header.Get("X-GitHub-Delivery"), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err := e.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err := h.DB.SaveEvents([]Event{*e}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveIngest saves events posted as a JSON array or, when the content type
+// is "application/x-ndjson", as newline-delimited JSON. Events are validated
+// and saved in a single transaction. If any event is rejected then nothing is
+// saved and the errors for each rejected event are returned.
+func (h *Handler) serveIngest(w http.ResponseWriter, r *http.Request) {
+	// Limit the size of the request.
+	maxSize := h.MaxIngestSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxIngestSize
+	}
+	body := http.MaxBytesReader(w, r.Body, maxSize)
+
+	// Read individual items from the body.
+	var items []json.RawMessage
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-ndjson" {
+		items, err = readNDJSON(body)
+	} else {
+		items, err = readJSONArray(body)
+	}
+	if _, ok := err.(*http.MaxBytesError); ok {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Decode & validate each item.
+	var resp ingestResponse
+	events := make([]Event, len(items))
+	for i, item := range items {
+		if err := json.Unmarshal(item, &events[i]); err != nil {
+			resp.Errors = append(resp.Errors, ingestError{Index: i, Error: err.Error()})
+		} else if err := events[i].Validate(); err != nil {
+			resp.Errors = append(resp.Errors, ingestError{Index: i, ID: events[i].ID, Error: err.Error()})
+		}
+	}
+
+	// Report rejected events without saving any.
+	if len(resp.Errors) > 0 {
+		writeJSON(w, http.StatusBadRequest, &resp)
+		return
+	}
+
+	// Save all events together.
+	if resp.Saved, err = h.DB.saveEvents(events); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, &resp)
+}
+
+// ingestResponse is the response body of an ingest request.
+type ingestResponse struct {
+	Saved  int           `json:"saved"`
+	Errors []ingestError `json:"errors,omitempty"`
+}
+
+// ingestError describes why an ingested event was rejected.
+type ingestError struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// readJSONArray returns the raw elements of a JSON array.
+func readJSONArray(r io.Reader) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// readNDJSON returns the raw values of each non-blank line.
+func readNDJSON(r io.Reader) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, json.RawMessage(line))
+		}
+
+		if err == io.EOF {
+			return items, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeJSON writes v as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print(err)
+	}
+}
+
+// parseQuery returns a database query from URL query parameters.
+func parseQuery(values url.Values) (*Query, error) {
+	var q Query
+
+	// Filter by fields. Parameters may be repeated to match multiple values.
+	q.Actors = values["actor"]
+	q.Repositories = values["repo"]
+	q.Types = values["type"]
+	q.Usernames = values["username"]
+
+	// Parse time range.
+	if s := values.Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since: %s", s)
+		}
+		q.Since = t
+	}
+	if s := values.Get("until"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until: %s", s)
+		}
+		q.Until = t
+	}
+
+	// Parse maximum number of events to return.
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit: %s", s)
+		}
+		q.Limit = n
+	}
+
+	// Resume after the last event of the previous page.
+	q.After = values.Get("after")
+
+	return &q, nil
+}
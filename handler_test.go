@@ -1 +1,771 @@
 package edb_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure events can be paged through using the "after" cursor.
+func TestHandler_Events_Paging(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	// Save events.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"},
+		{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retrieve first page.
+	w := h.MustServe("GET", "/events.json?limit=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if ids := EventIDs(MustDecodeEvents(w.Body.Bytes())); !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	// Follow the link to the next page.
+	next := MustNextLink(w.Header().Get("Link"))
+	w = h.MustServe("GET", next, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if ids := EventIDs(MustDecodeEvents(w.Body.Bytes())); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	} else if link := w.Header().Get("Link"); link != "" {
+		t.Fatalf("unexpected link: %s", link)
+	}
+}
+
+// Ensure an invalid cursor returns a bad request.
+func TestHandler_Events_ErrInvalidCursor(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	if w := h.MustServe("GET", "/events.json?after=!!!", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure events can be filtered by query parameters.
+func TestHandler_Events_Filter(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	// Save events.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Username: "bob"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob", Repository: "bob/bar", Username: "bob"},
+		{ID: "3", Type: "PullEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy", Repository: "bob/foo", Username: "susy"},
+		{ID: "4", Type: "PushEvent", Timestamp: MustParseTime("2000-03-01T00:00:00Z"), Actor: "susy", Repository: "susy/baz", Username: "susy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		url string
+		ids []string
+	}{
+		{url: "/events.json?actor=susy", ids: []string{"3", "4"}},
+		{url: "/events.json?repo=bob/foo&repo=susy/baz", ids: []string{"1", "3", "4"}},
+		{url: "/events.json?type=PushEvent&username=susy", ids: []string{"4"}},
+		{url: "/events.json?since=2000-01-06T00:00:00Z&until=2000-03-01T00:00:00Z", ids: []string{"3", "2"}},
+	} {
+		w := h.MustServe("GET", tt.url, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d. unexpected status: %d", i, w.Code)
+		} else if ids := EventIDs(MustDecodeEvents(w.Body.Bytes())); !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%d. unexpected ids: %v", i, ids)
+		}
+	}
+
+	// Invalid times return a bad request.
+	if w := h.MustServe("GET", "/events.json?since=yesterday", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure events can be retrieved by repository and type endpoints.
+func TestHandler_EventsByRepository(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	// Save events.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob", Repository: "bob/bar"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy", Repository: "bob/foo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		url string
+		ids []string
+	}{
+		{url: "/repos/bob/foo/events.json", ids: []string{"1", "3"}},
+		{url: "/repos/bob/foo/events.json?actor=susy", ids: []string{"3"}},
+		{url: "/types/IssueEvent/events.json", ids: []string{"2"}},
+	} {
+		w := h.MustServe("GET", tt.url, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d. unexpected status: %d", i, w.Code)
+		} else if ids := EventIDs(MustDecodeEvents(w.Body.Bytes())); !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%d. unexpected ids: %v", i, ids)
+		}
+	}
+}
+
+// Ensure event payloads can be omitted from list responses.
+func TestHandler_Events_Payload(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	// Save event with payload.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Org: "acme", Payload: json.RawMessage(`{"size":2}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Payloads are included by default.
+	if a := MustDecodeEvents(h.MustServe("GET", "/events.json", nil).Body.Bytes()); len(a) != 1 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if a[0].Org != "acme" {
+		t.Fatalf("unexpected org: %s", a[0].Org)
+	} else if string(MustCompactJSON(a[0].Payload)) != `{"size":2}` {
+		t.Fatalf("unexpected payload: %s", a[0].Payload)
+	}
+
+	// Payloads are stripped when requested.
+	if a := MustDecodeEvents(h.MustServe("GET", "/events.json?payload=false", nil).Body.Bytes()); len(a) != 1 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if a[0].Payload != nil {
+		t.Fatalf("unexpected payload: %s", a[0].Payload)
+	}
+}
+
+// Ensure events can be streamed as newline-delimited JSON.
+func TestHandler_Events_NDJSON(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Payload: json.RawMessage(`{"ref": "refs/heads/master"}`)},
+		{ID: "2", Type: "IssueEvent", Timestamp: MustParseTime("2000-02-01T00:00:00Z"), Actor: "bob"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "susy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Request by path.
+	w := h.MustServe("GET", "/events.ndjson?type=PushEvent", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if v := w.Header().Get("Content-Type"); v != "application/x-ndjson" {
+		t.Fatalf("unexpected content type: %s", v)
+	} else if s := w.Body.String(); s != `{"id":"1","type":"PushEvent","timestamp":"2000-01-01T00:00:00Z","username":"","actor":"bob","repository":"","payload":{"ref":"refs/heads/master"}}`+"\n"+
+		`{"id":"3","type":"PushEvent","timestamp":"2000-01-06T00:00:00Z","username":"","actor":"susy","repository":""}`+"\n" {
+		t.Fatalf("unexpected body: %s", s)
+	}
+
+	// Request by Accept header.
+	r, _ := http.NewRequest("GET", "/events.json?payload=false", nil)
+	r.Header.Set("Accept", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 {
+		t.Fatalf("unexpected line count: %d", len(lines))
+	} else if strings.Contains(lines[0], "payload") {
+		t.Fatalf("unexpected payload: %s", lines[0])
+	}
+
+	// Invalid cursors are reported before streaming.
+	if w := h.MustServe("GET", "/events.ndjson?after=!!!", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure events can be exported as CSV with the same filters.
+func TestHandler_Events_CSV(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: json.RawMessage(`{"ref":"refs/heads/master"}`)},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy", Repository: "susy/bar"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := h.MustServe("GET", "/events.csv?actor=bob&payload=false&column=ref", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if v := w.Header().Get("Content-Type"); !strings.HasPrefix(v, "text/csv") {
+		t.Fatalf("unexpected content type: %s", v)
+	} else if s := w.Body.String(); s != "id,type,timestamp,username,actor,repository,org,payload.ref\r\n"+
+		"1,PushEvent,2000-01-01T00:00:00Z,,bob,bob/foo,,refs/heads/master\r\n" {
+		t.Fatalf("unexpected body: %q", s)
+	}
+}
+
+// Ensure event counts can be retrieved by group.
+func TestHandler_Stats(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T12:00:00Z"), Actor: "bob", Repository: "bob/foo"},
+		{ID: "3", Type: "IssuesEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy", Repository: "bob/foo"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := h.MustServe("GET", "/stats?group=actor&interval=day&tz=UTC&repo=bob/foo", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if b := MustCompactJSON(w.Body.Bytes()); string(b) != `[{"time":"2000-01-01T00:00:00Z","actor":"bob","count":2},{"time":"2000-01-02T00:00:00Z","actor":"susy","count":1}]` {
+		t.Fatalf("unexpected body: %s", b)
+	}
+
+	// Invalid groups, intervals & time zones are rejected.
+	for _, u := range []string{"/stats?group=org", "/stats?interval=year", "/stats?interval=day&tz=Nowhere/Special"} {
+		if w := h.MustServe("GET", u, nil); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: unexpected status: %d", u, w.Code)
+		}
+	}
+}
+
+// Ensure a signed webhook delivery is saved and later replaced by the polled event.
+func TestHandler_GitHubWebhook(t *testing.T) {
+	h := NewHandler()
+	h.WebhookSecret = "secret"
+	defer h.Close()
+
+	body := []byte(`{"ref":"refs/heads/master","after":"abc123","sender":{"login":"bob"},"repository":{"full_name":"bob/foo"}}`)
+
+	// Deliver webhook.
+	r, _ := http.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-GitHub-Delivery", "d1")
+	r.Header.Set("X-Hub-Signature-256", Sign("secret", body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+
+	if a, err := h.DB.Events(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	} else if e := a[0]; e.ID != "webhook:d1" || e.Type != "PushEvent" || e.Actor != "bob" || e.Repository != "bob/foo" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+
+	// The same push fetched from the events API replaces the webhook event.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "100", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: json.RawMessage(`{"ref":"refs/heads/master","head":"abc123"}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := h.DB.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"100"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	// A late delivery of the same push is ignored.
+	r, _ = http.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-GitHub-Delivery", "d2")
+	r.Header.Set("X-Hub-Signature-256", Sign("secret", body))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if a, err := h.DB.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"100"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure a webhook delivery without a sender is rejected rather than failing to save.
+func TestHandler_GitHubWebhook_ErrEventActorRequired(t *testing.T) {
+	h := NewHandler()
+	h.WebhookSecret = "secret"
+	defer h.Close()
+
+	body := []byte(`{"ref":"refs/heads/master","after":"abc123","repository":{"full_name":"bob/foo"}}`)
+	r, _ := http.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-GitHub-Delivery", "d1")
+	r.Header.Set("X-Hub-Signature-256", Sign("secret", body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if body := strings.TrimSpace(w.Body.String()); body != edb.ErrEventActorRequired.Error() {
+		t.Fatalf("unexpected body: %s", body)
+	} else if a, _ := h.DB.Events(); len(a) != 0 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+}
+
+// Ensure a webhook delivery with an invalid signature is rejected.
+func TestHandler_GitHubWebhook_ErrInvalidSignature(t *testing.T) {
+	h := NewHandler()
+	h.WebhookSecret = "secret"
+	defer h.Close()
+
+	body := []byte(`{"sender":{"login":"bob"}}`)
+	r, _ := http.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-GitHub-Delivery", "d1")
+	r.Header.Set("X-Hub-Signature-256", Sign("wrong", body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if a, _ := h.DB.Events(); len(a) != 0 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+}
+
+// Ensure events can be ingested as a JSON array.
+func TestHandler_Ingest(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	w := h.MustServe("POST", "/events", strings.NewReader(`[
+		{"id":"deploy-1","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z","actor":"ci","repository":"acme/widgets"},
+		{"id":"deploy-2","type":"DeployEvent","timestamp":"2000-01-02T00:00:00Z","actor":"ci","repository":"acme/widgets"}
+	]`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"saved":2}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	if a, err := h.DB.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); !reflect.DeepEqual(ids, []string{"deploy-1", "deploy-2"}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
+
+// Ensure events skipped as duplicates of stored activity are not counted as saved.
+func TestHandler_Ingest_Duplicate(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "100", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob", Repository: "bob/foo", Payload: json.RawMessage(`{"ref":"refs/heads/master","head":"abc123"}`)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The webhook copy of the stored push is skipped.
+	w := h.MustServe("POST", "/events", strings.NewReader(`[
+		{"id":"webhook:d1","type":"PushEvent","timestamp":"2000-01-01T00:00:00Z","actor":"bob","repository":"bob/foo","payload":{"ref":"refs/heads/master","after":"abc123"}},
+		{"id":"deploy-1","type":"DeployEvent","timestamp":"2000-01-02T00:00:00Z","actor":"ci"}
+	]`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"saved":1}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+// Ensure events can be ingested as newline-delimited JSON.
+func TestHandler_Ingest_NDJSON(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	r, _ := http.NewRequest("POST", "/events", strings.NewReader(
+		`{"id":"1","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z","actor":"ci"}`+"\n\n"+
+			`{"id":"2","type":"ReleaseEvent","timestamp":"2000-01-02T00:00:00Z","actor":"ci"}`))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if a, _ := h.DB.Events(); len(a) != 2 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+}
+
+// Ensure invalid events are reported individually and nothing is saved.
+func TestHandler_Ingest_Invalid(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+
+	w := h.MustServe("POST", "/events", strings.NewReader(`[
+		{"id":"1","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z","actor":"ci"},
+		{"id":"2","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z"},
+		{"id":"3","type":"DeployEvent","timestamp":"yesterday","actor":"ci"},
+		{"id":"4","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z","actor":"ci\u0000x"},
+		{"id":"5","type":"DeployEvent","timestamp":"1500-01-01T00:00:00Z","actor":"ci"},
+		{"id":"6","type":"DeployEvent","timestamp":"2300-01-01T00:00:00Z","actor":"ci"}
+	]`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	var resp struct {
+		Saved  int
+		Errors []struct {
+			Index int
+			ID    string
+			Error string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	} else if resp.Saved != 0 || len(resp.Errors) != 5 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	} else if e := resp.Errors[0]; e.Index != 1 || e.ID != "2" || e.Error != edb.ErrEventActorRequired.Error() {
+		t.Fatalf("unexpected error(0): %+v", e)
+	} else if e := resp.Errors[1]; e.Index != 2 {
+		t.Fatalf("unexpected error(1): %+v", e)
+	} else if e := resp.Errors[2]; e.Index != 3 || e.Error != edb.ErrInvalidEventActor.Error() {
+		t.Fatalf("unexpected error(2): %+v", e)
+	} else if e := resp.Errors[3]; e.Index != 4 || e.Error != edb.ErrEventTimestampOutOfRange.Error() {
+		t.Fatalf("unexpected error(3): %+v", e)
+	} else if e := resp.Errors[4]; e.Index != 5 || e.Error != edb.ErrEventTimestampOutOfRange.Error() {
+		t.Fatalf("unexpected error(4): %+v", e)
+	}
+
+	if a, _ := h.DB.Events(); len(a) != 0 {
+		t.Fatalf("unexpected event count: %d", len(a))
+	}
+}
+
+// Ensure requests over the size limit are rejected.
+func TestHandler_Ingest_TooLarge(t *testing.T) {
+	h := NewHandler()
+	h.MaxIngestSize = 10
+	defer h.Close()
+
+	w := h.MustServe("POST", "/events", strings.NewReader(`[{"id":"1","type":"DeployEvent","timestamp":"2000-01-01T00:00:00Z","actor":"ci"}]`))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure requests require a token with the appropriate scope.
+func TestHandler_RequireAuth(t *testing.T) {
+	h := NewHandler()
+	h.RequireAuth = true
+	defer h.Close()
+
+	_, reader, err := h.DB.CreateToken("reader", []string{edb.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, admin, err := h.DB.CreateToken("admin", []string{edb.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		method string
+		url    string
+		token  string
+		body   string
+		code   int
+	}{
+		{method: "GET", url: "/events.json", code: http.StatusUnauthorized},
+		{method: "GET", url: "/events.json", token: "bad", code: http.StatusUnauthorized},
+		{method: "GET", url: "/events.json", token: reader, code: http.StatusOK},
+		{method: "GET", url: "/", code: http.StatusUnauthorized},
+		{method: "POST", url: "/events", token: reader, body: `[]`, code: http.StatusForbidden},
+		{method: "POST", url: "/events", token: admin, body: `[]`, code: http.StatusOK},
+	} {
+		r, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%d. %s %s: unexpected status: %d", i, tt.method, tt.url, w.Code)
+		} else if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%d. expected WWW-Authenticate header", i)
+		}
+	}
+}
+
+// Ensure assets can be served without a token while the API requires one.
+func TestHandler_RequireAuth_PublicAssets(t *testing.T) {
+	h := NewHandler()
+	h.RequireAuth = true
+	h.PublicAssets = true
+	h.WebhookSecret = "secret"
+	defer h.Close()
+
+	if w := h.MustServe("GET", "/", nil); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w := h.MustServe("GET", "/events.json", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	// Webhooks are authenticated by their signature.
+	body := []byte(`{}`)
+	r, _ := http.NewRequest("POST", "/webhooks/github", bytes.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "ping")
+	r.Header.Set("X-Hub-Signature-256", Sign("secret", body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure cross-origin requests are only allowed from configured origins.
+func TestHandler_CORS(t *testing.T) {
+	h := NewHandler()
+	h.CORS = &edb.CORS{AllowedOrigins: []string{"https://dash.example.com"}, MaxAge: 10 * time.Minute}
+	defer h.Close()
+
+	// Allowed origins can read the response.
+	r, _ := http.NewRequest("GET", "/events.json", nil)
+	r.Header.Set("Origin", "https://dash.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://dash.example.com" {
+		t.Fatalf("unexpected allow origin: %s", v)
+	} else if v := w.Header().Get("Vary"); v != "Origin" {
+		t.Fatalf("unexpected vary: %s", v)
+	}
+
+	// Other origins are not given access.
+	r.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if v := w.Header().Get("Access-Control-Allow-Origin"); v != "" {
+		t.Fatalf("unexpected allow origin: %s", v)
+	}
+}
+
+// Ensure preflight requests are answered before authentication.
+func TestHandler_CORS_Preflight(t *testing.T) {
+	h := NewHandler()
+	h.RequireAuth = true
+	h.CORS = &edb.CORS{AllowedOrigins: []string{"*"}, MaxAge: 10 * time.Minute}
+	defer h.Close()
+
+	r, _ := http.NewRequest("OPTIONS", "/events", nil)
+	r.Header.Set("Origin", "https://dash.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "authorization")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if v := w.Header().Get("Access-Control-Allow-Origin"); v != "*" {
+		t.Fatalf("unexpected allow origin: %s", v)
+	} else if v := w.Header().Get("Access-Control-Allow-Methods"); v != "GET, POST" {
+		t.Fatalf("unexpected allow methods: %s", v)
+	} else if v := w.Header().Get("Access-Control-Allow-Headers"); v != "Authorization, Content-Type" {
+		t.Fatalf("unexpected allow headers: %s", v)
+	} else if v := w.Header().Get("Access-Control-Max-Age"); v != "600" {
+		t.Fatalf("unexpected max age: %s", v)
+	}
+
+	// Preflight from a disallowed origin is rejected.
+	h.CORS.AllowedOrigins = []string{"https://other.example.com"}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure new events are streamed as server-sent events.
+func TestHandler_EventStream(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Open stream filtered to bob's events.
+	resp, err := http.Get(s.URL + "/events/stream?actor=bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	} else if v := resp.Header.Get("Content-Type"); v != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", v)
+	}
+	br := bufio.NewReader(resp.Body)
+
+	// Overwrites and other actors' events are not sent.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy"},
+		{ID: "3", Type: "IssuesEvent", Timestamp: MustParseTime("2000-01-03T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if id, e := MustReadServerSentEvent(br); e.ID != "3" || id != e.Cursor() {
+		t.Fatalf("unexpected event: %s %#v", id, e)
+	}
+}
+
+// Ensure a stream resumes after the Last-Event-ID.
+func TestHandler_EventStream_LastEventID(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	events := []edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "bob"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-03T00:00:00Z"), Actor: "bob"},
+	}
+	if err := h.DB.SaveEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", s.URL+"/events/stream", nil)
+	req.Header.Set("Last-Event-ID", events[0].Cursor())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+
+	// Missed events are sent first, then new ones.
+	if _, e := MustReadServerSentEvent(br); e.ID != "2" {
+		t.Fatalf("unexpected event: %#v", e)
+	} else if _, e := MustReadServerSentEvent(br); e.ID != "3" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "4", Type: "PushEvent", Timestamp: MustParseTime("2000-01-04T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, e := MustReadServerSentEvent(br); e.ID != "4" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+}
+
+// Handler represents a test wrapper for edb.Handler.
+type Handler struct {
+	*edb.Handler
+}
+
+// NewHandler returns a new instance of Handler with an open database.
+func NewHandler() *Handler {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		panic(err.Error())
+	}
+	return &Handler{Handler: &edb.Handler{DB: db}}
+}
+
+// Close closes the underlying database.
+func (h *Handler) Close() error { return h.DB.Close() }
+
+// MustServe executes a request against the handler and returns the recorder.
+func (h *Handler) MustServe(method, urlStr string, body io.Reader) *httptest.ResponseRecorder {
+	r, err := http.NewRequest(method, urlStr, body)
+	if err != nil {
+		panic(err.Error())
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// MustReadServerSentEvent reads the next message from an event stream and
+// returns its id and event. Comments are skipped.
+func MustReadServerSentEvent(br *bufio.Reader) (string, edb.Event) {
+	var id string
+	var e edb.Event
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			panic(err.Error())
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && id != "":
+			return id, e
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				panic(err.Error())
+			}
+		}
+	}
+}
+
+// MustDecodeEvents decodes a JSON list of events.
+func MustDecodeEvents(b []byte) []edb.Event {
+	var a []edb.Event
+	if err := json.Unmarshal(b, &a); err != nil {
+		panic(err.Error())
+	}
+	return a
+}
+
+// Sign returns the X-Hub-Signature-256 header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// MustCompactJSON returns b with insignificant whitespace removed.
+func MustCompactJSON(b []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		panic(err.Error())
+	}
+	return buf.Bytes()
+}
+
+// MustNextLink returns the URL of the "next" relation from a Link header.
+func MustNextLink(s string) string {
+	m := regexp.MustCompile(`<([^>]+)>; rel="next"`).FindStringSubmatch(s)
+	if m == nil {
+		panic("next link not found: " + s)
+	}
+	if _, err := url.Parse(m[1]); err != nil {
+		panic(err.Error())
+	}
+	return m[1]
+}
@@ -0,0 +1,176 @@
+package edb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// rollupDimensions are the event fields with daily counts in the rollups
+// bucket. Each has a sub-bucket mapping a UTC day and field value to the
+// number of events, e.g. "2015-01-02\x00benbjohnson" => 12.
+var rollupDimensions = []string{GroupActor, GroupRepository, GroupType}
+
+// rollupDayFormat is the layout of the day in a rollup key.
+const rollupDayFormat = "2006-01-02"
+
+// RebuildRollups regenerates the daily rollups from the stored events.
+func (db *DB) RebuildRollups() error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("rollups")) != nil {
+			if err := tx.DeleteBucket([]byte("rollups")); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucket([]byte("rollups")); err != nil {
+			return err
+		}
+
+		eventsBkt := tx.Bucket([]byte("events"))
+		return eventsBkt.ForEach(func(actor, _ []byte) error {
+			return eventsBkt.Bucket(actor).ForEach(func(_, v []byte) error {
+				var e Event
+				if err := json.Unmarshal(v, &e); err != nil {
+					return err
+				}
+				return updateRollups(tx, &e, 1)
+			})
+		})
+	})
+}
+
+// updateRollups adds delta to the daily counts of an event's fields.
+// Counts which reach zero are removed.
+func updateRollups(tx *bolt.Tx, e *Event, delta int64) error {
+	root := tx.Bucket([]byte("rollups"))
+	for _, dim := range rollupDimensions {
+		bkt, err := root.CreateBucketIfNotExists([]byte(dim))
+		if err != nil {
+			return err
+		}
+
+		key := rollupKey(e.Timestamp, rollupValue(e, dim))
+		var n int64
+		if v := bkt.Get(key); v != nil {
+			n = int64(binary.BigEndian.Uint64(v))
+		}
+
+		if n += delta; n <= 0 {
+			if err := bkt.Delete(key); err != nil {
+				return err
+			}
+		} else {
+			v := make([]byte, 8)
+			binary.BigEndian.PutUint64(v, uint64(n))
+			if err := bkt.Put(key, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// aggregateRollups counts events using the daily rollups. Returns false if
+// the aggregation cannot be answered from a single dimension's daily counts.
+func aggregateRollups(tx *bolt.Tx, a *Aggregation, loc *time.Location) (map[groupKey]int, bool) {
+	// Only days in UTC are stored.
+	if loc != time.UTC || (a.Interval != "" && a.Interval != IntervalDay && a.Interval != IntervalWeek && a.Interval != IntervalMonth) {
+		return nil, false
+	} else if !isUTCDay(a.Query.Since) || !isUTCDay(a.Query.Until) || len(a.Query.Usernames) > 0 {
+		return nil, false
+	}
+
+	// Grouping & filters must use the same dimension.
+	dims := append([]string{}, a.GroupBy...)
+	filters := map[string][]string{GroupActor: a.Query.Actors, GroupRepository: a.Query.Repositories, GroupType: a.Query.Types}
+	for dim, values := range filters {
+		if len(values) > 0 && !contains(dims, dim) {
+			dims = append(dims, dim)
+		}
+	}
+	if len(dims) > 1 {
+		return nil, false
+	}
+	dim := GroupType
+	if len(dims) == 1 {
+		dim = dims[0]
+	}
+
+	bkt := tx.Bucket([]byte("rollups")).Bucket([]byte(dim))
+	counts := make(map[groupKey]int)
+	if bkt == nil {
+		return counts, true
+	}
+
+	// Iterate over days within the time range.
+	c := bkt.Cursor()
+	var k, v []byte
+	if a.Query.Since.IsZero() {
+		k, v = c.First()
+	} else {
+		k, v = c.Seek([]byte(a.Query.Since.UTC().Format(rollupDayFormat)))
+	}
+	var until []byte
+	if !a.Query.Until.IsZero() {
+		until = []byte(a.Query.Until.UTC().Format(rollupDayFormat))
+	}
+	for ; k != nil; k, v = c.Next() {
+		if until != nil && bytes.Compare(k, until) >= 0 {
+			break
+		}
+
+		day, value := splitRollupKey(k)
+		if values := filters[dim]; len(values) > 0 && !contains(values, value) {
+			continue
+		}
+
+		var key groupKey
+		if a.Interval != "" {
+			key.time = truncateTime(day, a.Interval, loc).UnixNano()
+		}
+		if len(a.GroupBy) > 0 {
+			switch dim {
+			case GroupActor:
+				key.actor = value
+			case GroupRepository:
+				key.repository = value
+			case GroupType:
+				key.typ = value
+			}
+		}
+		counts[key] += int(binary.BigEndian.Uint64(v))
+	}
+
+	return counts, true
+}
+
+// rollupKey returns the key of the daily count for a field value.
+func rollupKey(t time.Time, value string) []byte {
+	return []byte(t.UTC().Format(rollupDayFormat) + "\x00" + value)
+}
+
+// splitRollupKey returns the day & field value of a rollup key.
+func splitRollupKey(k []byte) (time.Time, string) {
+	day, _ := time.Parse(rollupDayFormat, string(k[:len(rollupDayFormat)]))
+	return day, string(k[len(rollupDayFormat)+1:])
+}
+
+// rollupValue returns the value of an event's field for a dimension.
+func rollupValue(e *Event, dim string) string {
+	switch dim {
+	case GroupActor:
+		return e.Actor
+	case GroupRepository:
+		return e.Repository
+	default:
+		return e.Type
+	}
+}
+
+// isUTCDay returns true if t is zero or the start of a day in UTC.
+func isUTCDay(t time.Time) bool {
+	return t.IsZero() || t.UTC().Truncate(24*time.Hour).Equal(t)
+}
@@ -0,0 +1,137 @@
+package edb
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultFetchInterval is the default time between fetches from a source.
+const DefaultFetchInterval = 60 * time.Second
+
+// errClosing is returned when a fetch is interrupted by a close signal.
+var errClosing = errors.New("closing")
+
+// Source represents a provider of events, such as a code hosting service.
+type Source interface {
+	// Fetch returns events that have occurred since checkpoint along with
+	// the checkpoint to pass to the next call. An empty checkpoint means no
+	// events have been fetched before. Implementations should return early
+	// if closing is closed.
+	Fetch(closing chan struct{}, checkpoint string) ([]Event, string, error)
+}
+
+// Poller is implemented by sources which suggest a minimum time between
+// fetches, such as when a service advertises its own poll interval.
+type Poller interface {
+	PollInterval() time.Duration
+}
+
+// Fetcher periodically fetches new events from a source and saves them to
+// the database. The source's checkpoint is persisted between fetches so
+// ingestion can resume after a restart.
+type Fetcher struct {
+	// Name identifies the source in logs and names its checkpoint.
+	Name   string
+	Source Source
+	DB     *DB
+
+	// Minimum time between fetches. A Poller's interval is used instead
+	// when it is longer.
+	Interval time.Duration
+
+	// Delay before the first fetch. Used to spread out multiple fetchers.
+	Offset time.Duration
+
+	Logger *log.Logger
+}
+
+// NewFetcher returns a new Fetcher for a source.
+func NewFetcher(name string, src Source, db *DB) *Fetcher {
+	return &Fetcher{
+		Name:     name,
+		Source:   src,
+		DB:       db,
+		Interval: DefaultFetchInterval,
+
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Run fetches from the source on an interval until closing is closed.
+func (f *Fetcher) Run(closing chan struct{}) {
+	timer := time.NewTimer(f.Offset)
+	defer timer.Stop()
+
+	for {
+		// Wait for next fetch or close signal.
+		select {
+		case <-closing:
+			return
+		case <-timer.C:
+		}
+
+		f.fetch(closing)
+		timer.Reset(f.interval())
+	}
+}
+
+// interval returns the time to wait until the next fetch.
+func (f *Fetcher) interval() time.Duration {
+	if p, ok := f.Source.(Poller); ok && p.PollInterval() > f.Interval {
+		return p.PollInterval()
+	}
+	return f.Interval
+}
+
+// fetch retrieves and saves new events from the source.
+func (f *Fetcher) fetch(closing chan struct{}) {
+	f.Logger.Printf("fetching %s", f.Name)
+
+	// Read checkpoint from the last successful fetch.
+	checkpoint, err := f.DB.Checkpoint(f.Name)
+	if err != nil {
+		f.Logger.Printf("read checkpoint: %s", err)
+		return
+	}
+
+	// Fetch events from the source. Errors caused by shutdown are ignored.
+	events, next, err := f.Source.Fetch(closing, checkpoint)
+	if err != nil {
+		select {
+		case <-closing:
+		default:
+			f.Logger.Printf("fetch %s: %s", f.Name, err)
+		}
+		return
+	}
+
+	// Drop invalid events so they can't block the rest of the batch.
+	valid := events[:0]
+	for _, e := range events {
+		if err := e.Validate(); err != nil {
+			f.Logger.Printf("skip event %s from %s: %s", e.ID, f.Name, err)
+			continue
+		}
+		valid = append(valid, e)
+	}
+	events = valid
+
+	// Save events to database.
+	if len(events) > 0 {
+		if err := f.DB.SaveEvents(events); err != nil {
+			f.Logger.Printf("save events: %s", err)
+			return
+		}
+	}
+	f.Logger.Printf("saved %d events from %s", len(events), f.Name)
+
+	// Only move the checkpoint once the events are safely stored.
+	if next != checkpoint {
+		if err := f.DB.SetCheckpoint(f.Name, next); err != nil {
+			f.Logger.Printf("save checkpoint: %s", err)
+			return
+		}
+	}
+}
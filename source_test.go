@@ -0,0 +1,105 @@
+package edb_test
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure the fetcher saves events from its source and persists the checkpoint.
+func TestFetcher_Run(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Mock source returns a single event and expects an empty checkpoint.
+	var src Source
+	fetched := make(chan struct{}, 1)
+	src.FetchFn = func(closing chan struct{}, checkpoint string) ([]edb.Event, string, error) {
+		defer func() { fetched <- struct{}{} }()
+		if checkpoint != "" {
+			t.Errorf("unexpected checkpoint: %s", checkpoint)
+		}
+		return []edb.Event{{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"}}, "1", nil
+	}
+
+	f := edb.NewFetcher("mock/bob", &src, db)
+	f.Interval = time.Hour
+	f.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Run fetcher until the first fetch completes.
+	closing, done := make(chan struct{}), make(chan struct{})
+	go func() { f.Run(closing); close(done) }()
+	<-fetched
+	close(closing)
+	<-done
+
+	// Verify event and checkpoint were saved.
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if v, err := db.Checkpoint("mock/bob"); err != nil {
+		t.Fatal(err)
+	} else if v != "1" {
+		t.Fatalf("unexpected checkpoint: %s", v)
+	}
+}
+
+// Ensure invalid events are skipped without blocking the rest of the batch.
+func TestFetcher_Run_InvalidEvent(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Mock source returns an event without an actor alongside a valid one.
+	var src Source
+	fetched := make(chan struct{}, 1)
+	src.FetchFn = func(closing chan struct{}, checkpoint string) ([]edb.Event, string, error) {
+		defer func() { fetched <- struct{}{} }()
+		return []edb.Event{
+			{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z")},
+			{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "bob"},
+		}, "2", nil
+	}
+
+	f := edb.NewFetcher("mock/bob", &src, db)
+	f.Interval = time.Hour
+	f.Logger = log.New(ioutil.Discard, "", 0)
+
+	// Run fetcher until the first fetch completes.
+	closing, done := make(chan struct{}), make(chan struct{})
+	go func() { f.Run(closing); close(done) }()
+	<-fetched
+	close(closing)
+	<-done
+
+	// Verify the valid event was saved and the checkpoint advanced.
+	if a, err := db.Events(); err != nil {
+		t.Fatal(err)
+	} else if ids := EventIDs(a); len(ids) != 1 || ids[0] != "2" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if v, err := db.Checkpoint("mock/bob"); err != nil {
+		t.Fatal(err)
+	} else if v != "2" {
+		t.Fatalf("unexpected checkpoint: %s", v)
+	}
+}
+
+// Source represents a mock implementation of edb.Source.
+type Source struct {
+	FetchFn func(closing chan struct{}, checkpoint string) ([]edb.Event, string, error)
+}
+
+func (s *Source) Fetch(closing chan struct{}, checkpoint string) ([]edb.Event, string, error) {
+	return s.FetchFn(closing, checkpoint)
+}
@@ -0,0 +1,145 @@
+package edb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Token scopes.
+const (
+	// ScopeRead allows events and assets to be read.
+	ScopeRead = "read"
+
+	// ScopeIngest allows events to be written.
+	ScopeIngest = "ingest"
+
+	// ScopeAdmin allows everything.
+	ScopeAdmin = "admin"
+)
+
+var (
+	// ErrTokenNotFound is returned when revoking a token that does not exist.
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrInvalidScope is returned when creating a token with an unknown scope.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Token represents an API token. Only a hash of the token's secret is
+// stored so the secret is only available when the token is created.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasScope returns true if the token grants scope. Admin tokens grant all scopes.
+func (t *Token) HasScope(scope string) bool {
+	return contains(t.Scopes, scope) || contains(t.Scopes, ScopeAdmin)
+}
+
+// CreateToken generates a new token with the given scopes. Returns the token
+// and its secret, which cannot be retrieved later.
+func (db *DB) CreateToken(name string, scopes []string) (*Token, string, error) {
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeIngest && scope != ScopeAdmin {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	// Generate random id & secret.
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	t := &Token{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := db.db.Update(func(tx *bolt.Tx) error {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("tokens")).Put(hashSecret(secret), b)
+	}); err != nil {
+		return nil, "", err
+	}
+
+	return t, secret, nil
+}
+
+// Tokens returns a list of all tokens.
+func (db *DB) Tokens() ([]Token, error) {
+	var tokens []Token
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("tokens")).ForEach(func(_, v []byte) error {
+			var t Token
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+	})
+	return tokens, err
+}
+
+// RevokeToken deletes a token by id.
+func (db *DB) RevokeToken(id string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("tokens")).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var t Token
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			} else if t.ID == id {
+				return c.Delete()
+			}
+		}
+		return ErrTokenNotFound
+	})
+}
+
+// Authenticate returns the token for a secret. Returns nil if no token matches.
+func (db *DB) Authenticate(secret string) (*Token, error) {
+	var t *Token
+	err := db.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte("tokens")).Get(hashSecret(secret))
+		if v == nil {
+			return nil
+		}
+		t = &Token{}
+		return json.Unmarshal(v, t)
+	})
+	return t, err
+}
+
+// hashSecret returns the key a token is stored under.
+func hashSecret(secret string) []byte {
+	h := sha256.Sum256([]byte(secret))
+	return h[:]
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
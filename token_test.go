@@ -0,0 +1,75 @@
+package edb_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/edb"
+)
+
+// Ensure tokens can be created, authenticated, listed and revoked.
+func TestDB_Token(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create token.
+	tok, secret, err := db.CreateToken("dashboard", []string{edb.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	} else if tok.ID == "" || secret == "" {
+		t.Fatalf("unexpected token: %#v", tok)
+	}
+
+	// Authenticate with the secret.
+	if other, err := db.Authenticate(secret); err != nil {
+		t.Fatal(err)
+	} else if other == nil || other.ID != tok.ID || other.Name != "dashboard" {
+		t.Fatalf("unexpected token: %#v", other)
+	} else if !other.HasScope(edb.ScopeRead) || other.HasScope(edb.ScopeIngest) {
+		t.Fatalf("unexpected scopes: %v", other.Scopes)
+	}
+
+	// Unknown secrets don't authenticate.
+	if other, err := db.Authenticate("bad"); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatalf("unexpected token: %#v", other)
+	}
+
+	// List tokens.
+	if a, err := db.Tokens(); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].ID != tok.ID {
+		t.Fatalf("unexpected tokens: %#v", a)
+	}
+
+	// Revoke token.
+	if err := db.RevokeToken(tok.ID); err != nil {
+		t.Fatal(err)
+	} else if err := db.RevokeToken(tok.ID); err != edb.ErrTokenNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if other, _ := db.Authenticate(secret); other != nil {
+		t.Fatalf("unexpected token: %#v", other)
+	}
+}
+
+// Ensure admin tokens grant every scope and unknown scopes are rejected.
+func TestDB_CreateToken_Scopes(t *testing.T) {
+	db := edb.NewDB()
+	if err := db.Open(MustTempFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if tok, _, err := db.CreateToken("admin", []string{edb.ScopeAdmin}); err != nil {
+		t.Fatal(err)
+	} else if !tok.HasScope(edb.ScopeRead) || !tok.HasScope(edb.ScopeIngest) {
+		t.Fatalf("unexpected scopes: %v", tok.Scopes)
+	}
+
+	if _, _, err := db.CreateToken("bad", []string{"write"}); err != edb.ErrInvalidScope {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package edb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WebhookIDPrefix prefixes the ids of events received by webhook. These events
+// are provisional: when the same activity is later fetched from the events
+// API, the webhook event is replaced by the fetched one.
+const WebhookIDPrefix = "webhook:"
+
+var (
+	// ErrInvalidSignature is returned when a webhook's signature does not match.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrDeliveryRequired is returned when a webhook has no delivery id.
+	ErrDeliveryRequired = errors.New("delivery id required")
+)
+
+// VerifyGitHubSignature validates the X-Hub-Signature-256 header of a webhook
+// delivery against the HMAC-SHA256 of its body.
+func VerifyGitHubSignature(secret, signature string, body []byte) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// NewGitHubWebhookEvent returns an event from a webhook delivery. The name is
+// the X-GitHub-Event header, e.g. "pull_request", which is converted to the
+// events API type, e.g. "PullRequestEvent". The event is timestamped with the
+// time of receipt since deliveries have no common timestamp field.
+func NewGitHubWebhookEvent(name, delivery string, body []byte) (*Event, error) {
+	if delivery == "" {
+		return nil, ErrDeliveryRequired
+	}
+
+	var payload struct {
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %s", err)
+	}
+
+	return &Event{
+		ID:         WebhookIDPrefix + delivery,
+		Type:       githubEventType(name),
+		Timestamp:  time.Now().UTC(),
+		Username:   payload.Sender.Login,
+		Actor:      payload.Sender.Login,
+		Repository: payload.Repository.FullName,
+		Org:        payload.Organization.Login,
+		Payload:    json.RawMessage(body),
+	}, nil
+}
+
+// githubEventType converts a webhook event name to an events API type.
+func githubEventType(name string) string {
+	var s string
+	for _, word := range strings.Split(name, "_") {
+		if word != "" {
+			s += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return s + "Event"
+}
+
+// fingerprint returns a key identifying the underlying activity of a GitHub
+// event. Webhook deliveries and events API records for the same activity
+// share a fingerprint even though their ids and payload shapes differ.
+// Returns nil if the event is not from GitHub or cannot be fingerprinted.
+func fingerprint(e *Event) []byte {
+	if len(e.Payload) == 0 || !isGitHubEventID(e.ID) {
+		return nil
+	}
+
+	var p struct {
+		Action  string `json:"action"`
+		Ref     string `json:"ref"`
+		RefType string `json:"ref_type"`
+		Head    string `json:"head"`  // events API push
+		After   string `json:"after"` // webhook push
+		Number  int    `json:"number"`
+
+		Comment     *struct{ ID int64 } `json:"comment"`
+		Review      *struct{ ID int64 } `json:"review"`
+		Release     *struct{ ID int64 } `json:"release"`
+		Forkee      *struct{ ID int64 } `json:"forkee"`
+		PullRequest *struct {
+			Number    int    `json:"number"`
+			UpdatedAt string `json:"updated_at"`
+		} `json:"pull_request"`
+		Issue *struct {
+			Number    int    `json:"number"`
+			UpdatedAt string `json:"updated_at"`
+		} `json:"issue"`
+	}
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return nil
+	}
+
+	// Determine the most specific identifier of the activity.
+	var key string
+	switch {
+	case p.Comment != nil:
+		key = fmt.Sprintf("comment:%d:%s", p.Comment.ID, p.Action)
+	case p.Review != nil:
+		key = fmt.Sprintf("review:%d:%s", p.Review.ID, p.Action)
+	case p.Release != nil:
+		key = fmt.Sprintf("release:%d:%s", p.Release.ID, p.Action)
+	case p.Forkee != nil:
+		key = fmt.Sprintf("fork:%d", p.Forkee.ID)
+	case p.PullRequest != nil:
+		key = fmt.Sprintf("pull:%d:%s:%s", p.PullRequest.Number, p.Action, p.PullRequest.UpdatedAt)
+	case p.Issue != nil:
+		key = fmt.Sprintf("issue:%d:%s:%s", p.Issue.Number, p.Action, p.Issue.UpdatedAt)
+	case p.Head != "":
+		key = fmt.Sprintf("push:%s:%s", p.Ref, p.Head)
+	case p.After != "":
+		key = fmt.Sprintf("push:%s:%s", p.Ref, p.After)
+	case p.RefType != "":
+		key = fmt.Sprintf("ref:%s:%s", p.RefType, p.Ref)
+	default:
+		return nil
+	}
+
+	return []byte(strings.Join([]string{e.Type, e.Repository, e.Actor, key}, "\x00"))
+}
+
+// isGitHubEventID returns true if id is a webhook id or a numeric events API id.
+func isGitHubEventID(id string) bool {
+	if strings.HasPrefix(id, WebhookIDPrefix) {
+		return true
+	} else if id == "" {
+		return false
+	}
+	for _, ch := range id {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package edb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// MaxWebSocketBackfill is the maximum number of past events sent when a
+// WebSocket client subscribes.
+const MaxWebSocketBackfill = 1000
+
+// serveWebSocket serves live queries over a WebSocket connection.
+//
+// Clients send a "subscribe" message with the actors, repos, types and
+// usernames to match and the number of past events to backfill. The server
+// replies with the backfilled events, oldest first, then a "subscribed"
+// message, followed by new events as they are saved. Sending another
+// "subscribe" replaces the filter and "unsubscribe" stops events.
+//
+// The server sends a "ping" every keepalive interval and closes the
+// connection if nothing is received from the client for two intervals.
+// Clients should reply with a "pong" and may send their own "ping".
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	s := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error { return h.checkWebSocketOrigin(r) },
+		Handler:   h.handleWebSocket,
+	}
+	s.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin returns an error if a browser on another origin is not
+// allowed to connect. Browsers don't apply CORS to WebSockets so the server must.
+func (h *Handler) checkWebSocketOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	} else if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	} else if h.CORS != nil && h.CORS.allowOrigin(origin) {
+		return nil
+	}
+	return fmt.Errorf("origin not allowed: %s", origin)
+}
+
+// handleWebSocket runs a live query session until the connection closes.
+func (h *Handler) handleWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	interval := h.keepAliveInterval()
+
+	// Read messages from the client in a separate goroutine.
+	msgs, errs := make(chan webSocketMessage), make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var msg webSocketMessage
+			_ = ws.SetReadDeadline(time.Now().Add(2 * interval))
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case msgs <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(msg *webSocketMessage) error {
+		_ = ws.SetWriteDeadline(time.Now().Add(interval))
+		return websocket.JSON.Send(ws, msg)
+	}
+
+	// Events for the current filter. Nil until the client subscribes.
+	var events <-chan Event
+	cancel := func() {}
+	defer func() { cancel() }()
+
+	// Backfilled events, in case they are also received from the subscription.
+	var sent map[string]bool
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-errs:
+			return
+
+		case msg := <-msgs:
+			switch msg.Type {
+			case "subscribe":
+				cancel()
+				events, cancel, sent, err = h.subscribeWebSocket(&msg, send)
+			case "unsubscribe":
+				cancel()
+				events, cancel = nil, func() {}
+			case "ping":
+				err = send(&webSocketMessage{Type: "pong"})
+			case "pong":
+			default:
+				err = send(&webSocketMessage{Type: "error", Error: fmt.Sprintf("unknown message type: %s", msg.Type)})
+			}
+
+		case e, ok := <-events:
+			// The subscription closes if we fall behind. The client
+			// reconnects and backfills to recover.
+			if !ok {
+				return
+			} else if sent[string(eventRef(&e))] {
+				continue
+			}
+			err = send(&webSocketMessage{Type: "event", Event: &e})
+
+		case <-ticker.C:
+			err = send(&webSocketMessage{Type: "ping"})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// subscribeWebSocket subscribes to new events matching the message's filter
+// and sends the backfilled events. Returns the subscription and the set of
+// events already sent.
+func (h *Handler) subscribeWebSocket(msg *webSocketMessage, send func(*webSocketMessage) error) (<-chan Event, func(), map[string]bool, error) {
+	q := &Query{
+		Actors:       msg.Actors,
+		Repositories: msg.Repositories,
+		Types:        msg.Types,
+		Usernames:    msg.Usernames,
+	}
+
+	// Subscribe before reading past events so none fall in between.
+	events, cancel := h.DB.Subscribe(q)
+
+	// Send the most recent matching events, oldest first.
+	sent := make(map[string]bool)
+	if n := msg.Backfill; n > 0 {
+		if n > MaxWebSocketBackfill {
+			n = MaxWebSocketBackfill
+		}
+		backfill := *q
+		backfill.Reverse, backfill.Limit = true, n
+
+		a, err := h.DB.Query(&backfill)
+		if err != nil {
+			return events, cancel, sent, send(&webSocketMessage{Type: "error", Error: err.Error()})
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			if err := send(&webSocketMessage{Type: "event", Event: &a[i]}); err != nil {
+				return events, cancel, sent, err
+			}
+			sent[string(eventRef(&a[i]))] = true
+		}
+	}
+
+	return events, cancel, sent, send(&webSocketMessage{Type: "subscribed"})
+}
+
+// webSocketMessage is a message sent in either direction on a WebSocket.
+type webSocketMessage struct {
+	Type string `json:"type"`
+
+	// Filter & backfill size sent by the client to subscribe.
+	Actors       []string `json:"actors,omitempty"`
+	Repositories []string `json:"repos,omitempty"`
+	Types        []string `json:"types,omitempty"`
+	Usernames    []string `json:"usernames,omitempty"`
+	Backfill     int      `json:"backfill,omitempty"`
+
+	// Sent by the server.
+	Event *Event `json:"event,omitempty"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,164 @@
+package edb_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/edb"
+	"golang.org/x/net/websocket"
+)
+
+// Ensure a WebSocket client can backfill, receive new events and change its filter.
+func TestHandler_WebSocket(t *testing.T) {
+	h := NewHandler()
+	defer h.Close()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "1", Type: "PushEvent", Timestamp: MustParseTime("2000-01-01T00:00:00Z"), Actor: "bob"},
+		{ID: "2", Type: "PushEvent", Timestamp: MustParseTime("2000-01-02T00:00:00Z"), Actor: "susy"},
+		{ID: "3", Type: "PushEvent", Timestamp: MustParseTime("2000-01-03T00:00:00Z"), Actor: "bob"},
+		{ID: "4", Type: "PushEvent", Timestamp: MustParseTime("2000-01-04T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := MustDialWebSocket(s.URL, s.URL)
+	defer ws.Close()
+
+	// Subscribe to bob's events with a backfill of the last two.
+	MustSendWebSocket(ws, `{"type":"subscribe","actors":["bob"],"backfill":2}`)
+	if ids := ReceiveWebSocketEventIDs(ws); !reflect.DeepEqual(ids, []string{"3", "4"}) {
+		t.Fatalf("unexpected backfill: %v", ids)
+	}
+
+	// New matching events are sent.
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "5", Type: "PushEvent", Timestamp: MustParseTime("2000-01-05T00:00:00Z"), Actor: "susy"},
+		{ID: "6", Type: "PushEvent", Timestamp: MustParseTime("2000-01-06T00:00:00Z"), Actor: "bob"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := MustReceiveWebSocket(ws); msg["type"] != "event" || msg["event"].(map[string]interface{})["id"] != "6" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	// Change the filter without reconnecting.
+	MustSendWebSocket(ws, `{"type":"subscribe","actors":["susy"]}`)
+	if ids := ReceiveWebSocketEventIDs(ws); len(ids) != 0 {
+		t.Fatalf("unexpected backfill: %v", ids)
+	}
+	if err := h.DB.SaveEvents([]edb.Event{
+		{ID: "7", Type: "PushEvent", Timestamp: MustParseTime("2000-01-07T00:00:00Z"), Actor: "bob"},
+		{ID: "8", Type: "PushEvent", Timestamp: MustParseTime("2000-01-08T00:00:00Z"), Actor: "susy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := MustReceiveWebSocket(ws); msg["type"] != "event" || msg["event"].(map[string]interface{})["id"] != "8" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	// Pings from the client are answered.
+	MustSendWebSocket(ws, `{"type":"ping"}`)
+	if msg := MustReceiveWebSocket(ws); msg["type"] != "pong" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+}
+
+// Ensure clients which don't answer pings are disconnected.
+func TestHandler_WebSocket_KeepAlive(t *testing.T) {
+	h := NewHandler()
+	h.KeepAliveInterval = 20 * time.Millisecond
+	defer h.Close()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	ws := MustDialWebSocket(s.URL, s.URL)
+	defer ws.Close()
+
+	if msg := MustReceiveWebSocket(ws); msg["type"] != "ping" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	// Ignore pings until the server gives up.
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			break
+		} else if msg["type"] != "ping" {
+			t.Fatalf("unexpected message: %v", msg)
+		}
+	}
+}
+
+// Ensure browsers on disallowed origins cannot connect.
+func TestHandler_WebSocket_ErrOriginNotAllowed(t *testing.T) {
+	h := NewHandler()
+	h.CORS = &edb.CORS{AllowedOrigins: []string{"https://dash.example.com"}}
+	defer h.Close()
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/events/ws"
+	if _, err := websocket.Dial(url, "", "https://evil.example.com"); err == nil {
+		t.Fatal("expected error")
+	}
+	ws, err := websocket.Dial(url, "", "https://dash.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+}
+
+// MustDialWebSocket connects to the WebSocket endpoint of a test server.
+func MustDialWebSocket(serverURL, origin string) *websocket.Conn {
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/events/ws", "", origin)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ws
+}
+
+// MustSendWebSocket sends a JSON message.
+func MustSendWebSocket(ws *websocket.Conn, s string) {
+	if _, err := ws.Write([]byte(s)); err != nil {
+		panic(err.Error())
+	}
+}
+
+// MustReceiveWebSocket receives the next JSON message.
+func MustReceiveWebSocket(ws *websocket.Conn) map[string]interface{} {
+	var b []byte
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := websocket.Message.Receive(ws, &b); err != nil {
+		panic(err.Error())
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		panic(err.Error())
+	}
+	return msg
+}
+
+// ReceiveWebSocketEventIDs returns the ids of events received until "subscribed".
+func ReceiveWebSocketEventIDs(ws *websocket.Conn) []string {
+	var ids []string
+	for {
+		msg := MustReceiveWebSocket(ws)
+		switch msg["type"] {
+		case "event":
+			ids = append(ids, msg["event"].(map[string]interface{})["id"].(string))
+		case "subscribed":
+			return ids
+		default:
+			panic("unexpected message: " + msg["type"].(string))
+		}
+	}
+}